@@ -0,0 +1,47 @@
+package consensus
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// CommitCertificate lists the individual commit signatures the leader
+// aggregated into the committed message of a block, so that the participation
+// in the round can be audited later.
+type CommitCertificate struct {
+	ViewID    uint64      `json:"viewID"`
+	BlockNum  uint64      `json:"blockNum"`
+	BlockHash common.Hash `json:"blockHash"`
+	// hex encoded commit signature, keyed by the hex encoded BLS public key
+	Signatures map[string]string `json:"signatures"`
+}
+
+// newCommitCertificate collects the commit signatures of the block being
+// finalized, caller's responsibility to hold the consensus mutex.
+func (consensus *Consensus) newCommitCertificate() *CommitCertificate {
+	cert := &CommitCertificate{
+		ViewID:     consensus.viewID,
+		BlockNum:   consensus.blockNum,
+		BlockHash:  consensus.blockHash,
+		Signatures: make(map[string]string, len(consensus.commitSigs)),
+	}
+	for key, sig := range consensus.commitSigs {
+		cert.Signatures[key] = sig.SerializeToHexStr()
+	}
+	return cert
+}
+
+// recordCommitCertificate keeps the certificate of a block once it is
+// committed to the chain.
+func (consensus *Consensus) recordCommitCertificate(cert *CommitCertificate) {
+	consensus.commitCertMutex.Lock()
+	consensus.commitCert = cert
+	consensus.commitCertMutex.Unlock()
+}
+
+// CommitCertificate returns the commit signatures of the last block this node
+// finalized as leader, or nil if it hasn't finalized any.
+func (consensus *Consensus) CommitCertificate() *CommitCertificate {
+	consensus.commitCertMutex.Lock()
+	defer consensus.commitCertMutex.Unlock()
+	return consensus.commitCert
+}
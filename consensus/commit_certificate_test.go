@@ -0,0 +1,102 @@
+package consensus
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	ffi_bls "github.com/harmony-one/bls/ffi/go/bls"
+
+	"github.com/harmony-one/harmony/core"
+	"github.com/harmony-one/harmony/core/types"
+	"github.com/harmony-one/harmony/core/vm"
+	chain2 "github.com/harmony-one/harmony/internal/chain"
+	"github.com/harmony-one/harmony/internal/params"
+	"github.com/harmony-one/harmony/internal/utils"
+	"github.com/harmony-one/harmony/p2p/p2pimpl"
+)
+
+func TestCommitCertificate(t *testing.T) {
+	consensus := &Consensus{viewID: 7, blockNum: 5, blockHash: common.Hash{0x01}}
+	if consensus.CommitCertificate() != nil {
+		t.Error("Expected no certificate before any block is finalized")
+	}
+
+	_, peers, priKeys := NewTestCommittee(3)
+	commitPayload := ConstructCommitPayload(consensus.blockNum, consensus.blockHash)
+	consensus.commitSigs = map[string]*ffi_bls.Sign{}
+	for i, peer := range peers {
+		consensus.commitSigs[peer.ConsensusPubKey.SerializeToHexStr()] = priKeys[i].SignHash(commitPayload)
+	}
+	consensus.recordCommitCertificate(consensus.newCommitCertificate())
+	consensus.commitSigs = map[string]*ffi_bls.Sign{}
+
+	cert := consensus.CommitCertificate()
+	if cert == nil || cert.ViewID != 7 || cert.BlockNum != 5 || cert.BlockHash != consensus.blockHash {
+		t.Fatalf("Certificate does not describe the finalized block: %+v", cert)
+	}
+	if len(cert.Signatures) != len(peers) {
+		t.Errorf("Expected %d signatures, got %d", len(peers), len(cert.Signatures))
+	}
+
+	encoded, err := json.Marshal(cert)
+	if err != nil {
+		t.Fatalf("Cannot serialize certificate: %v", err)
+	}
+	decoded := CommitCertificate{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Cannot deserialize certificate: %v", err)
+	}
+	for i, peer := range peers {
+		sig := ffi_bls.Sign{}
+		if err := sig.DeserializeHexStr(decoded.Signatures[peer.ConsensusPubKey.SerializeToHexStr()]); err != nil {
+			t.Fatalf("Cannot decode signature of validator %d: %v", i, err)
+		}
+		if !sig.VerifyHash(peer.ConsensusPubKey, commitPayload) {
+			t.Errorf("Signature of validator %d does not verify after round trip", i)
+		}
+	}
+}
+
+func TestCommitCertificateNotRecordedWhenCatchupFails(test *testing.T) {
+	leader, _, priKeys := NewTestCommittee(1)
+	p2pPriKey, _, _ := utils.GenKeyP2P("127.0.0.1", "9902")
+	host, err := p2pimpl.NewHost(&leader, p2pPriKey)
+	if err != nil {
+		test.Fatalf("newhost failure: %v", err)
+	}
+	consensus, err := New(host, 0, leader, priKeys[0])
+	if err != nil {
+		test.Fatalf("Cannot craeate consensus: %v", err)
+	}
+	defer consensus.Close()
+	consensus.UpdatePublicKeys([]*ffi_bls.PublicKey{leader.ConsensusPubKey})
+
+	database := ethdb.NewMemDatabase()
+	gspec := core.Genesis{Config: params.TestChainConfig, ShardID: 0}
+	gspec.MustCommit(database)
+	consensus.ChainReader, err = core.NewBlockChain(database, nil, gspec.Config, chain2.Engine, vm.Config{}, nil)
+	if err != nil {
+		test.Fatalf("Cannot create blockchain: %v", err)
+	}
+
+	// the block doesn't extend the genesis block, so catchup cannot commit it
+	consensus.blockNum = 1
+	consensus.announce(types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), ParentHash: common.Hash{0x02}}))
+	select {
+	case <-consensus.commitFinishChan:
+	case <-time.After(time.Second):
+		test.Fatal("Single node should not wait for commits from others")
+	}
+
+	consensus.finalizeCommits()
+	if consensus.blockNum != 1 {
+		test.Fatalf("Block should not have been committed, now at block %d", consensus.blockNum)
+	}
+	if cert := consensus.CommitCertificate(); cert != nil {
+		test.Errorf("Expected no certificate for a block that was not committed, got %+v", cert)
+	}
+}
@@ -0,0 +1,70 @@
+package consensus
+
+import (
+	"sync"
+
+	"github.com/harmony-one/harmony/core/types"
+)
+
+// committedBlock is a block together with the view ID it was committed in
+type committedBlock struct {
+	viewID uint64
+	block  *types.Block
+}
+
+// committedBlocks keeps the most recently committed blocks, evicting the
+// oldest one once it holds size blocks.
+type committedBlocks struct {
+	mutex  sync.Mutex
+	size   int
+	blocks []committedBlock // oldest first
+}
+
+// add records a block committed in the given view
+func (c *committedBlocks) add(viewID uint64, block *types.Block) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.size <= 0 {
+		return
+	}
+	if len(c.blocks) >= c.size {
+		c.blocks = append(c.blocks[:0], c.blocks[len(c.blocks)-c.size+1:]...)
+	}
+	c.blocks = append(c.blocks, committedBlock{viewID: viewID, block: block})
+}
+
+// get returns the block committed in the given view, if still kept
+func (c *committedBlocks) get(viewID uint64) (*types.Block, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i := len(c.blocks) - 1; i >= 0; i-- {
+		if c.blocks[i].viewID == viewID {
+			return c.blocks[i].block, true
+		}
+	}
+	return nil, false
+}
+
+// resize changes how many blocks are kept, dropping the oldest ones if needed
+func (c *committedBlocks) resize(size int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.size = size
+	if size <= 0 {
+		c.blocks = nil
+	} else if len(c.blocks) > size {
+		c.blocks = append(c.blocks[:0], c.blocks[len(c.blocks)-size:]...)
+	}
+}
+
+// BlockForView returns the block committed in the given view ID, as long as
+// it is still among the recently committed blocks kept by this node.
+func (consensus *Consensus) BlockForView(viewID uint64) (*types.Block, bool) {
+	return consensus.committedBlocks.get(viewID)
+}
+
+// SetCommittedBlocksSize sets how many recently committed blocks are kept
+// for BlockForView.  It is safe to call while consensus is running.
+func (consensus *Consensus) SetCommittedBlocksSize(size int) {
+	consensus.committedBlocks.resize(size)
+}
@@ -0,0 +1,33 @@
+package consensus
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/harmony-one/harmony/core/types"
+)
+
+func TestCommittedBlocks(t *testing.T) {
+	consensus := &Consensus{}
+	consensus.SetCommittedBlocksSize(2)
+	for i := uint64(1); i <= 3; i++ {
+		block := types.NewBlockWithHeader(&types.Header{Number: new(big.Int).SetUint64(i)})
+		consensus.committedBlocks.add(10+i, block)
+	}
+
+	if _, ok := consensus.BlockForView(11); ok {
+		t.Error("the oldest block should have been evicted")
+	}
+	block, ok := consensus.BlockForView(13)
+	if !ok || block.NumberU64() != 3 {
+		t.Errorf("expected block 3 for view 13, got %v", block)
+	}
+
+	consensus.SetCommittedBlocksSize(1)
+	if _, ok := consensus.BlockForView(12); ok {
+		t.Error("shrinking should evict the oldest block")
+	}
+	if _, ok := consensus.BlockForView(13); !ok {
+		t.Error("shrinking should keep the newest block")
+	}
+}
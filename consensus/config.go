@@ -15,6 +15,13 @@ const (
 	maxLogSize        uint32        = 1000
 	// threshold between received consensus message blockNum and my blockNum
 	consensusBlockNumBuffer uint64 = 2
+	// how long the leader keeps collecting commits after reaching quorum
+	defaultCommitGracePeriod time.Duration = 2 * time.Second
+	// how many recently committed blocks are kept for BlockForView
+	defaultCommittedBlocksSize = 64
+	// how many received messages wait for the main loop, so that the manager
+	// can hand them over without blocking on a busy shard
+	msgChanSize = 64
 )
 
 // TimeoutType is the type of timeout in view change protocol
@@ -10,6 +10,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/harmony-one/bls/ffi/go/bls"
+	"github.com/rs/zerolog"
 
 	"github.com/harmony-one/harmony/contracts/structs"
 	"github.com/harmony-one/harmony/core"
@@ -29,6 +30,13 @@ const (
 
 // Consensus is the main struct with all states and data related to consensus process.
 type Consensus struct {
+	// Monotonic outcome counters, updated with sync/atomic; kept first in
+	// the struct for 64-bit alignment on 32-bit platforms
+	roundsCommitted   uint64
+	roundsTimedOut    uint64
+	roundsViewChanged uint64
+	messagesRejected  uint64
+
 	// PbftLog stores the pbft messages and blocks during PBFT process
 	PbftLog *PbftLog
 	// phase: different phase of PBFT protocol: pre-prepare, prepare, commit, finish etc
@@ -47,6 +55,11 @@ type Consensus struct {
 	// How long to delay sending commit messages.
 	delayCommit time.Duration
 
+	// How long the leader waits for more commits once quorum is reached.
+	commitGracePeriod time.Duration
+	// how far the announced block's timestamp may be from the local clock, 0 to disable
+	maxBlockTimeDrift time.Duration
+
 	// Consensus rounds whose commit phase finished
 	commitFinishChan chan uint64
 
@@ -81,6 +94,12 @@ type Consensus struct {
 	// If the number of validators is less than minPeers, the consensus won't start
 	MinPeers int
 
+	// Limits on the blocks a validator accepts from the leader, 0 for no limit:
+	// the size of the encoded block in bytes and its number of transactions
+	MinBlockBytes int
+	MaxBlockBytes int
+	MaxTxCount    int
+
 	// Leader's address
 	leader p2p.Peer
 
@@ -95,7 +114,8 @@ type Consensus struct {
 	PubKey *bls.PublicKey
 
 	SelfAddress common.Address
-	// the publickey of leader
+	// the publickey of leader, read with GetLeaderPubKey and written with
+	// SetLeaderPubKey, which hold leaderMutex
 	LeaderPubKey *bls.PublicKey
 
 	// number of publickeys of previous epoch
@@ -122,17 +142,40 @@ type Consensus struct {
 	// consensus information update mutex
 	infoMutex sync.Mutex
 
-	// Signal channel for starting a new consensus process
+	// Signal channel for starting a new consensus process, holds at most one
+	// pending signal (see signalReady)
 	ReadySignal chan struct{}
+	// guards leader and LeaderPubKey
+	leaderMutex sync.Mutex
+	// Closed by Close() to stop the main loop and its helper goroutines
+	done      chan struct{}
+	closeOnce sync.Once
+	// Consecutive view changes without a committed block; once
+	// maxConsecutiveFailures is reached the leader stops proposing until Resume
+	haltMutex              sync.Mutex
+	consecutiveFailures    int
+	maxConsecutiveFailures int
+	halted                 bool
 	// The post-consensus processing func passed from Node object
 	// Called when consensus on a new block is done
 	OnConsensusDone func(*types.Block)
+	// The optional func called once a block's commit signature is verified
+	// and the block committed, with the view ID it was committed in
+	OnFinalized func(viewID uint64, height uint64, blockHash common.Hash)
 	// The verifier func passed from Node object
 	BlockVerifier func(*types.Block) error
+	// The optional header verifier func, called on the announced block header
+	// before the validator sends its prepare message
+	HeaderVerifier func(*types.Header) error
 
 	// verified block to state sync broadcast
 	VerifiedNewBlock chan *types.Block
 
+	// Optional channel receiving an event on every phase switch, never blocks consensus
+	EventChan chan Event
+	// lets WaitForPhase observe phase switches
+	phaseWatch phaseWatch
+
 	// will trigger state syncing when blockNum is low
 	blockNumLowChan chan struct{}
 
@@ -152,6 +195,9 @@ type Consensus struct {
 	// Staking information finder
 	stakeInfoFinder StakeInfoFinder
 
+	// Decides the next leader during view change, round robin if nil
+	leaderSelector LeaderSelector
+
 	// Used to convey to the consensus main loop that block syncing has finished.
 	syncReadyChan chan struct{}
 	// Used to convey to the consensus main loop that node is out of sync
@@ -162,6 +208,33 @@ type Consensus struct {
 
 	// last node block reward for metrics
 	lastBlockReward *big.Int
+
+	// round statistics reported by Stats()
+	roundsCompleted    uint64
+	totalRoundDuration time.Duration
+	lastRoundFinish    time.Time
+	// when the leader announced the current block, and the validator latencies since then
+	announceTime   time.Time
+	roundLatencies map[string]time.Duration // key is the bls public key
+	avgLatencies   map[string]time.Duration // key is the bls public key
+
+	// minimum level of the logs of this consensus instance, nil to follow the global level
+	logLevel *zerolog.Level
+
+	// recently committed blocks by view ID
+	committedBlocks committedBlocks
+
+	// which validators signed the last committed block, by index in PublicKeys
+	lastSignersMutex sync.Mutex
+	lastSigners      []bool
+
+	// commit signatures of the last block finalized as leader
+	commitCertMutex sync.Mutex
+	commitCert      *CommitCertificate
+
+	// number of proposals violating the block limits, by leader bls public key
+	blockLimitViolationsMutex sync.Mutex
+	blockLimitViolations      map[string]uint64
 }
 
 // SetCommitDelay sets the commit message delay.  If set to non-zero,
@@ -170,6 +243,34 @@ func (consensus *Consensus) SetCommitDelay(delay time.Duration) {
 	consensus.delayCommit = delay
 }
 
+// SetCommitGracePeriod sets how long the leader keeps collecting commit
+// messages after reaching quorum, before finalizing with the signatures it
+// has.  More signatures mean more validators earn the block reward.
+func (consensus *Consensus) SetCommitGracePeriod(period time.Duration) {
+	consensus.commitGracePeriod = period
+}
+
+// SetMaxBlockTimeDrift sets how far the timestamp of an announced block may
+// be from the validator's local clock before the block is rejected, which
+// keeps a leader from backdating or postdating blocks.  Zero, the default,
+// disables the check.
+func (consensus *Consensus) SetMaxBlockTimeDrift(drift time.Duration) {
+	consensus.maxBlockTimeDrift = drift
+}
+
+// SetLogLevel sets the minimum level of the logs of this consensus instance,
+// so that each shard of a multi-shard process can be tuned separately.
+func (consensus *Consensus) SetLogLevel(level zerolog.Level) {
+	consensus.logLevel = &level
+}
+
+// SetConsensusTimeout sets how long a node waits in the announce, prepare
+// or commit phase before proposing a view change.  It defaults to
+// phaseDuration; tests may use a shorter value.
+func (consensus *Consensus) SetConsensusTimeout(d time.Duration) {
+	consensus.consensusTimeout[timeoutConsensus].SetDuration(d)
+}
+
 // StakeInfoFinder returns the stake information finder instance this
 // consensus uses, e.g. for block reward distribution.
 func (consensus *Consensus) StakeInfoFinder() StakeInfoFinder {
@@ -203,6 +304,110 @@ func (consensus *Consensus) BlocksNotSynchronized() {
 	consensus.syncNotReadyChan <- struct{}{}
 }
 
+// Close stops the consensus main loop started by Start, the goroutines it
+// spawned and the message retries, after which received messages are dropped.
+// It is safe to call Close more than once.
+func (consensus *Consensus) Close() {
+	consensus.closeOnce.Do(func() {
+		if consensus.done != nil {
+			close(consensus.done)
+		}
+		if consensus.msgSender != nil {
+			consensus.msgSender.StopAllRetries()
+		}
+	})
+}
+
+// IsClosed returns whether Close has been called.
+func (consensus *Consensus) IsClosed() bool {
+	select {
+	case <-consensus.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// PushMessage passes a received message payload to the main loop, waiting
+// until the loop takes it.  Once the consensus is closed the message is
+// dropped and PushMessage returns false.
+func (consensus *Consensus) PushMessage(payload []byte) bool {
+	select {
+	case consensus.MsgChan <- payload:
+		return true
+	case <-consensus.done:
+		return false
+	}
+}
+
+// SetMaxConsecutiveFailures sets after how many consecutive rounds ending in a
+// view change the node halts and stops proposing blocks.  Zero, the default,
+// never halts.
+func (consensus *Consensus) SetMaxConsecutiveFailures(max int) {
+	consensus.haltMutex.Lock()
+	defer consensus.haltMutex.Unlock()
+	consensus.maxConsecutiveFailures = max
+}
+
+// IsHalted returns whether the node stopped proposing blocks after too many
+// consecutive failed rounds.
+func (consensus *Consensus) IsHalted() bool {
+	consensus.haltMutex.Lock()
+	defer consensus.haltMutex.Unlock()
+	return consensus.halted
+}
+
+// Resume lets a halted node propose blocks again and clears its failure count.
+// A halted leader dropped its last proposal, so it is signalled to make a new one.
+func (consensus *Consensus) Resume() {
+	consensus.haltMutex.Lock()
+	wasHalted := consensus.halted
+	consensus.halted = false
+	consensus.consecutiveFailures = 0
+	consensus.haltMutex.Unlock()
+
+	if wasHalted && consensus.IsLeader() {
+		consensus.signalReady()
+	}
+}
+
+// recordRoundFailure counts a round that ended in a view change and halts the
+// node once maxConsecutiveFailures is reached.
+func (consensus *Consensus) recordRoundFailure() {
+	consensus.haltMutex.Lock()
+	consensus.consecutiveFailures++
+	halting := !consensus.halted && consensus.maxConsecutiveFailures > 0 &&
+		consensus.consecutiveFailures >= consensus.maxConsecutiveFailures
+	if halting {
+		consensus.halted = true
+	}
+	failures := consensus.consecutiveFailures
+	consensus.haltMutex.Unlock()
+
+	if halting {
+		consensus.getLogger().Error().
+			Int("consecutiveFailures", failures).
+			Msg("Too many consecutive failed rounds, halting block proposal until Resume")
+		consensus.emitEvent()
+	}
+}
+
+// recordRoundSuccess clears the failure count after a block is committed.
+func (consensus *Consensus) recordRoundSuccess() {
+	consensus.haltMutex.Lock()
+	defer consensus.haltMutex.Unlock()
+	consensus.consecutiveFailures = 0
+}
+
+// signalReady tells the node to propose the next block.  It never blocks:
+// signals sent before the node reads the pending one coalesce into it.
+func (consensus *Consensus) signalReady() {
+	select {
+	case consensus.ReadySignal <- struct{}{}:
+	default:
+	}
+}
+
 // WaitForSyncing informs the node syncing service to start syncing
 func (consensus *Consensus) WaitForSyncing() {
 	<-consensus.blockNumLowChan
@@ -213,6 +418,14 @@ func (consensus *Consensus) Quorum() int {
 	return len(consensus.PublicKeys)*2/3 + 1
 }
 
+// HasQuorum returns whether the given number of collected signatures reaches
+// the quorum of the current committee.  The quorum is derived from
+// PublicKeys on every call, so it follows committee updates; committees of
+// 1 to 3 nodes require every member to sign.
+func (consensus *Consensus) HasQuorum(collected int) bool {
+	return collected >= consensus.Quorum()
+}
+
 // PreviousQuorum returns the quorum size of previous epoch
 func (consensus *Consensus) PreviousQuorum() int {
 	return consensus.numPrevPubKeys*2/3 + 1
@@ -264,6 +477,8 @@ func New(host p2p.Host, ShardID uint32, leader p2p.Peer, blsPriKey *bls.SecretKe
 
 	consensus.prepareSigs = map[string]*bls.Sign{}
 	consensus.commitSigs = map[string]*bls.Sign{}
+	consensus.commitGracePeriod = defaultCommitGracePeriod
+	consensus.committedBlocks.resize(defaultCommittedBlocksSize)
 
 	consensus.CommitteePublicKeys = make(map[string]bool)
 
@@ -283,12 +498,13 @@ func New(host p2p.Host, ShardID uint32, leader p2p.Peer, blsPriKey *bls.SecretKe
 	consensus.viewID = 0
 	consensus.ShardID = ShardID
 
-	consensus.MsgChan = make(chan []byte)
+	consensus.MsgChan = make(chan []byte, msgChanSize)
 	consensus.syncReadyChan = make(chan struct{})
 	consensus.syncNotReadyChan = make(chan struct{})
 	consensus.commitFinishChan = make(chan uint64)
 
-	consensus.ReadySignal = make(chan struct{})
+	consensus.ReadySignal = make(chan struct{}, 1)
+	consensus.done = make(chan struct{})
 	consensus.lastBlockReward = big.NewInt(0)
 
 	// channel for receiving newly generated VDF
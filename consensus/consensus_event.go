@@ -0,0 +1,85 @@
+package consensus
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Event describes a phase switch of the consensus state machine, or the node
+// halting after too many failed rounds.
+type Event struct {
+	Phase    PbftPhase
+	Mode     Mode
+	ViewID   uint64
+	BlockNum uint64
+	Halted   bool
+	Time     time.Time
+}
+
+// emitEvent wakes up WaitForPhase callers and sends the current phase to
+// EventChan if it is set. The event is dropped if nobody is ready to receive it.
+func (consensus *Consensus) emitEvent() {
+	consensus.phaseWatch.notify(consensus.phase)
+	if consensus.EventChan == nil {
+		return
+	}
+	event := Event{
+		Phase:    consensus.phase,
+		Mode:     consensus.mode.Mode(),
+		ViewID:   consensus.viewID,
+		BlockNum: consensus.blockNum,
+		Halted:   consensus.IsHalted(),
+		Time:     time.Now(),
+	}
+	select {
+	case consensus.EventChan <- event:
+	default:
+		consensus.getLogger().Debug().
+			Str("phase", event.Phase.String()).
+			Msg("[emitEvent] event channel is full, dropping event")
+	}
+}
+
+// phaseWatch broadcasts phase switches to any number of waiters. It has its
+// own mutex so waiting never contends with the consensus mutex.
+type phaseWatch struct {
+	mutex   sync.Mutex
+	phase   PbftPhase
+	changed chan struct{} // closed on the next phase switch
+}
+
+func (w *phaseWatch) notify(phase PbftPhase) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.phase = phase
+	if w.changed != nil {
+		close(w.changed)
+		w.changed = nil
+	}
+}
+
+// WaitForPhase blocks until the consensus switches to the target phase, or
+// returns immediately if it is already there. It returns the context's error
+// if ctx is done first.
+func (consensus *Consensus) WaitForPhase(ctx context.Context, target PbftPhase) error {
+	w := &consensus.phaseWatch
+	for {
+		w.mutex.Lock()
+		if w.phase == target {
+			w.mutex.Unlock()
+			return nil
+		}
+		if w.changed == nil {
+			w.changed = make(chan struct{})
+		}
+		changed := w.changed
+		w.mutex.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
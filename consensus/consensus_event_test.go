@@ -0,0 +1,72 @@
+package consensus
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEmitEvent(t *testing.T) {
+	consensus := &Consensus{viewID: 3, blockNum: 2}
+	// no channel set, must not block nor panic
+	consensus.switchPhase(Prepare, true)
+
+	consensus.EventChan = make(chan Event, 1)
+	consensus.switchPhase(Commit, true)
+	// channel is full, must not block
+	consensus.switchPhase(Announce, true)
+
+	event := <-consensus.EventChan
+	if event.Phase != Commit || event.ViewID != 3 || event.BlockNum != 2 {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+	if len(consensus.EventChan) != 0 {
+		t.Error("Event should have been dropped while the channel was full")
+	}
+
+	consensus.switchPhase(Announce, true)
+	if len(consensus.EventChan) != 0 {
+		t.Error("Setting the current phase again should not emit an event")
+	}
+}
+
+func TestWaitForPhase(t *testing.T) {
+	consensus := &Consensus{}
+	if err := consensus.WaitForPhase(context.Background(), Announce); err != nil {
+		t.Errorf("Already in the target phase, should not wait: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- consensus.WaitForPhase(context.Background(), Commit)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	consensus.switchPhase(Prepare, false)
+	consensus.switchPhase(Commit, false)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("WaitForPhase returned an error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitForPhase did not return after switching to the target phase")
+	}
+
+	// re-setting the current phase must not wake up the waiters
+	consensus.phaseWatch.mutex.Lock()
+	changed := make(chan struct{})
+	consensus.phaseWatch.changed = changed
+	consensus.phaseWatch.mutex.Unlock()
+	consensus.switchPhase(Commit, true)
+	select {
+	case <-changed:
+		t.Error("Waiters were woken up without a phase switch")
+	default:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := consensus.WaitForPhase(ctx, Prepare); err != context.DeadlineExceeded {
+		t.Errorf("Expected the context deadline to be exceeded, got %v", err)
+	}
+}
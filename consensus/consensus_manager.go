@@ -0,0 +1,104 @@
+package consensus
+
+import (
+	"fmt"
+	"sync"
+
+	protobuf "github.com/golang/protobuf/proto"
+
+	msg_pb "github.com/harmony-one/harmony/api/proto/message"
+	"github.com/harmony-one/harmony/core/types"
+	"github.com/harmony-one/harmony/internal/utils"
+)
+
+// Manager holds the consensus instances of a node taking part in several
+// shards and routes incoming consensus messages to the right one.
+// Each consensus instance keeps its own mutex, so shards do not block each other.
+type Manager struct {
+	consensuses map[uint32]*Consensus
+	mutex       sync.RWMutex
+}
+
+// ShardChannels are the channels passed to Start for the consensus of a shard.
+type ShardChannels struct {
+	BlockChannel chan *types.Block
+	StopChan     chan struct{}
+	StoppedChan  chan struct{}
+	StartChannel chan struct{}
+}
+
+// NewManager returns a new consensus manager with no shard.
+func NewManager() *Manager {
+	return &Manager{consensuses: make(map[uint32]*Consensus)}
+}
+
+// AddConsensus registers the consensus instance for its shard.
+func (manager *Manager) AddConsensus(consensus *Consensus) error {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	if _, ok := manager.consensuses[consensus.ShardID]; ok {
+		return fmt.Errorf("consensus for shard %d already registered", consensus.ShardID)
+	}
+	manager.consensuses[consensus.ShardID] = consensus
+	return nil
+}
+
+// GetConsensus returns the consensus instance of the given shard, or nil if
+// the node does not take part in that shard.
+func (manager *Manager) GetConsensus(shardID uint32) *Consensus {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+	return manager.consensuses[shardID]
+}
+
+// HandleMessage passes the consensus message payload to the consensus
+// instance of the shard the message belongs to.
+func (manager *Manager) HandleMessage(payload []byte) error {
+	msg := &msg_pb.Message{}
+	if err := protobuf.Unmarshal(payload, msg); err != nil {
+		return err
+	}
+	var shardID uint32
+	if vcMsg := msg.GetViewchange(); vcMsg != nil {
+		shardID = vcMsg.ShardId
+	} else if consensusMsg := msg.GetConsensus(); consensusMsg != nil {
+		shardID = consensusMsg.ShardId
+	} else {
+		return fmt.Errorf("not a consensus message: %s", msg.Type)
+	}
+	consensus := manager.GetConsensus(shardID)
+	if consensus == nil {
+		return fmt.Errorf("no consensus for shard %d", shardID)
+	}
+	if consensus.IsClosed() {
+		return fmt.Errorf("consensus for shard %d is closed", shardID)
+	}
+	// never wait on a busy shard, it would hold up the messages of all shards
+	select {
+	case consensus.MsgChan <- payload:
+		return nil
+	default:
+		utils.Logger().Warn().
+			Uint32("shardID", shardID).
+			Str("msgType", msg.Type.String()).
+			Msg("[Manager] Consensus busy, dropping message")
+		return fmt.Errorf("consensus for shard %d is busy, message dropped", shardID)
+	}
+}
+
+// RunAll starts the main loop of every registered consensus instance with the
+// channels given for its shard.  Nothing is started if a shard has no channels.
+func (manager *Manager) RunAll(channels map[uint32]ShardChannels) error {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+	for shardID := range manager.consensuses {
+		if _, ok := channels[shardID]; !ok {
+			return fmt.Errorf("no channels for the consensus of shard %d", shardID)
+		}
+	}
+	for shardID, consensus := range manager.consensuses {
+		c := channels[shardID]
+		consensus.Start(c.BlockChannel, c.StopChan, c.StoppedChan, c.StartChannel)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package consensus
+
+import (
+	"testing"
+	"time"
+
+	protobuf "github.com/golang/protobuf/proto"
+
+	msg_pb "github.com/harmony-one/harmony/api/proto/message"
+	"github.com/harmony-one/harmony/core/types"
+	"github.com/harmony-one/harmony/crypto/bls"
+	"github.com/harmony-one/harmony/internal/utils"
+	"github.com/harmony-one/harmony/p2p"
+	"github.com/harmony-one/harmony/p2p/p2pimpl"
+)
+
+func TestManagerHandleMessage(t *testing.T) {
+	manager := NewManager()
+	shard0 := &Consensus{ShardID: 0, MsgChan: make(chan []byte, 1)}
+	shard1 := &Consensus{ShardID: 1, MsgChan: make(chan []byte, 1)}
+	if err := manager.AddConsensus(shard0); err != nil {
+		t.Fatalf("Cannot add consensus: %s", err)
+	}
+	if err := manager.AddConsensus(shard1); err != nil {
+		t.Fatalf("Cannot add consensus: %s", err)
+	}
+	if err := manager.AddConsensus(&Consensus{ShardID: 1}); err == nil {
+		t.Error("Adding a second consensus for the same shard should fail")
+	}
+
+	msg := &msg_pb.Message{
+		ServiceType: msg_pb.ServiceType_CONSENSUS,
+		Type:        msg_pb.MessageType_PREPARE,
+		Request: &msg_pb.Message_Consensus{
+			Consensus: &msg_pb.ConsensusRequest{ShardId: 1},
+		},
+	}
+	payload, _ := protobuf.Marshal(msg)
+	if err := manager.HandleMessage(payload); err != nil {
+		t.Fatalf("Cannot handle message: %s", err)
+	}
+	if len(shard1.MsgChan) != 1 || len(shard0.MsgChan) != 0 {
+		t.Error("Message was not routed to the consensus of shard 1")
+	}
+
+	if err := manager.HandleMessage(payload); err == nil {
+		t.Error("Message for a busy shard should be dropped rather than block")
+	}
+
+	shard1.done = make(chan struct{})
+	shard1.Close()
+	<-shard1.MsgChan
+	if err := manager.HandleMessage(payload); err == nil || len(shard1.MsgChan) != 0 {
+		t.Error("Message for a closed shard should be dropped")
+	}
+
+	msg.GetConsensus().ShardId = 2
+	payload, _ = protobuf.Marshal(msg)
+	if err := manager.HandleMessage(payload); err == nil {
+		t.Error("Message for an unknown shard should be rejected")
+	}
+}
+
+func TestManagerRunAll(t *testing.T) {
+	leader := p2p.Peer{IP: "127.0.0.1", Port: "9902"}
+	priKey, _, _ := utils.GenKeyP2P("127.0.0.1", "9902")
+	host, err := p2pimpl.NewHost(&leader, priKey)
+	if err != nil {
+		t.Fatalf("newhost failure: %v", err)
+	}
+	manager := NewManager()
+	channels := map[uint32]ShardChannels{}
+	for shardID := uint32(0); shardID < 2; shardID++ {
+		consensus, err := New(host, shardID, leader, bls.RandPrivateKey())
+		if err != nil {
+			t.Fatalf("Cannot craeate consensus: %v", err)
+		}
+		if err := manager.AddConsensus(consensus); err != nil {
+			t.Fatalf("Cannot add consensus: %s", err)
+		}
+		channels[shardID] = ShardChannels{
+			BlockChannel: make(chan *types.Block),
+			StopChan:     make(chan struct{}),
+			StoppedChan:  make(chan struct{}),
+			StartChannel: make(chan struct{}),
+		}
+	}
+
+	if err := manager.RunAll(map[uint32]ShardChannels{0: channels[0]}); err == nil {
+		t.Error("RunAll should fail when a shard has no channels")
+	}
+	if err := manager.RunAll(channels); err != nil {
+		t.Fatalf("Cannot run all shards: %s", err)
+	}
+	for shardID, c := range channels {
+		manager.GetConsensus(shardID).Close()
+		select {
+		case <-c.StoppedChan:
+		case <-time.After(5 * time.Second):
+			t.Errorf("Consensus of shard %d did not run or did not stop", shardID)
+		}
+	}
+}
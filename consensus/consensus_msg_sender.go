@@ -1,6 +1,7 @@
 package consensus
 
 import (
+	"math/rand"
 	"sync"
 	"time"
 
@@ -23,6 +24,8 @@ type MessageSender struct {
 	host p2p.Host
 	// RetryTimes is number of retry attempts
 	retryTimes int
+	// Up to how much longer than the retry interval to wait, picked randomly for each retry
+	retryJitter time.Duration
 }
 
 // MessageRetry controls the message that can be retried
@@ -41,6 +44,21 @@ func NewMessageSender(host p2p.Host) *MessageSender {
 	return &MessageSender{host: host, retryTimes: int(phaseDuration.Seconds()) / RetryIntervalInSec}
 }
 
+// SetRetryJitter sets the maximum random delay added to each retry interval,
+// so that the retries of different nodes don't all fire at the same time.
+func (sender *MessageSender) SetRetryJitter(jitter time.Duration) {
+	sender.retryJitter = jitter
+}
+
+// retryInterval returns how long to wait before the next retry.
+func (sender *MessageSender) retryInterval() time.Duration {
+	interval := RetryIntervalInSec * time.Second
+	if sender.retryJitter > 0 {
+		interval += time.Duration(rand.Int63n(int64(sender.retryJitter)))
+	}
+	return interval
+}
+
 // Reset resets the sender's state for new block
 func (sender *MessageSender) Reset(blockNum uint64) {
 	sender.blockNumMutex.Lock()
@@ -78,7 +96,7 @@ func (sender *MessageSender) SendWithoutRetry(groups []p2p.GroupID, p2pMsg []byt
 // Retry will retry the consensus message for <RetryTimes> times.
 func (sender *MessageSender) Retry(msgRetry *MessageRetry) {
 	for {
-		time.Sleep(RetryIntervalInSec * time.Second)
+		time.Sleep(sender.retryInterval())
 
 		if msgRetry.retryCount >= sender.retryTimes {
 			// Retried enough times
@@ -136,3 +154,15 @@ func (sender *MessageSender) StopAllRetriesExceptCommitted() {
 		return true
 	})
 }
+
+// StopAllRetries stops all the existing retries, including the committed message.
+func (sender *MessageSender) StopAllRetries() {
+	sender.messagesToRetry.Range(func(k, v interface{}) bool {
+		if msgRetry, ok := v.(*MessageRetry); ok {
+			msgRetry.isActiveMutex.Lock()
+			msgRetry.isActive = false
+			msgRetry.isActiveMutex.Unlock()
+		}
+		return true
+	})
+}
@@ -0,0 +1,43 @@
+package consensus
+
+import (
+	"testing"
+	"time"
+
+	msg_pb "github.com/harmony-one/harmony/api/proto/message"
+)
+
+func TestRetryInterval(t *testing.T) {
+	sender := &MessageSender{}
+	interval := RetryIntervalInSec * time.Second
+	if got := sender.retryInterval(); got != interval {
+		t.Errorf("Expected %v without jitter, got %v", interval, got)
+	}
+
+	jitter := time.Second
+	sender.SetRetryJitter(jitter)
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 100; i++ {
+		got := sender.retryInterval()
+		if got < interval || got >= interval+jitter {
+			t.Fatalf("Retry interval %v is outside [%v, %v)", got, interval, interval+jitter)
+		}
+		seen[got] = true
+	}
+	if len(seen) < 50 {
+		t.Errorf("Expected retry intervals spread across the jitter, got %d distinct values", len(seen))
+	}
+}
+
+func TestStopAllRetries(t *testing.T) {
+	sender := &MessageSender{}
+	prepared := &MessageRetry{msgType: msg_pb.MessageType_PREPARED, isActive: true}
+	committed := &MessageRetry{msgType: msg_pb.MessageType_COMMITTED, isActive: true}
+	sender.messagesToRetry.Store(prepared.msgType, prepared)
+	sender.messagesToRetry.Store(committed.msgType, committed)
+
+	sender.StopAllRetries()
+	if prepared.isActive || committed.isActive {
+		t.Error("All retries, including the committed message, should be stopped")
+	}
+}
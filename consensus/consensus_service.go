@@ -1,10 +1,13 @@
 package consensus
 
 import (
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"math/big"
+	"sort"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -89,13 +92,44 @@ func (consensus *Consensus) signAndMarshalConsensusMessage(message *msg_pb.Messa
 
 // SetLeaderPubKey deserialize the public key of consensus leader
 func (consensus *Consensus) SetLeaderPubKey(k []byte) error {
-	consensus.leader.ConsensusPubKey = &bls.PublicKey{}
-	return consensus.leader.ConsensusPubKey.Deserialize(k)
+	leaderPubKey := &bls.PublicKey{}
+	if err := leaderPubKey.Deserialize(k); err != nil {
+		return err
+	}
+	consensus.setLeaderPubKey(leaderPubKey)
+	return nil
 }
 
 // GetLeaderPubKey returns the public key of consensus leader
 func (consensus *Consensus) GetLeaderPubKey() *bls.PublicKey {
-	return consensus.leader.ConsensusPubKey
+	consensus.leaderMutex.Lock()
+	defer consensus.leaderMutex.Unlock()
+	return consensus.LeaderPubKey
+}
+
+// CurrentLeader returns the peer currently leading consensus, as updated by
+// view changes and committed blocks.  Only the public key is set if the
+// leader is not among the known validator peers.
+func (consensus *Consensus) CurrentLeader() p2p.Peer {
+	consensus.leaderMutex.Lock()
+	defer consensus.leaderMutex.Unlock()
+	return consensus.leader
+}
+
+// setLeaderPubKey updates LeaderPubKey along with the leader peer
+func (consensus *Consensus) setLeaderPubKey(leaderPubKey *bls.PublicKey) {
+	leader := p2p.Peer{ConsensusPubKey: leaderPubKey}
+	if leaderPubKey != nil {
+		if v, ok := consensus.validators.Load(leaderPubKey.SerializeToHexStr()); ok {
+			if peer, ok := v.(p2p.Peer); ok {
+				leader = peer
+			}
+		}
+	}
+	consensus.leaderMutex.Lock()
+	defer consensus.leaderMutex.Unlock()
+	consensus.LeaderPubKey = leaderPubKey
+	consensus.leader = leader
 }
 
 // GetNodeIDs returns Node IDs of all nodes in the same shard
@@ -126,8 +160,13 @@ func (consensus *Consensus) DebugPrintPublicKeys() {
 	utils.Logger().Debug().Strs("PublicKeys", keys).Int("count", len(keys)).Msgf("Debug Public Keys")
 }
 
-// UpdatePublicKeys updates the PublicKeys variable, protected by a mutex
-func (consensus *Consensus) UpdatePublicKeys(pubKeys []*bls.PublicKey) int {
+// UpdatePublicKeys updates the PublicKeys variable, protected by a mutex.
+// The committee is left unchanged if pubKeys is empty or has nil or
+// duplicate keys.
+func (consensus *Consensus) UpdatePublicKeys(pubKeys []*bls.PublicKey) (int, error) {
+	if err := validatePublicKeys(pubKeys); err != nil {
+		return len(consensus.PublicKeys), err
+	}
 	consensus.pubKeyLock.Lock()
 	consensus.PublicKeys = append(pubKeys[:0:0], pubKeys...)
 	consensus.CommitteePublicKeys = map[string]bool{}
@@ -137,16 +176,65 @@ func (consensus *Consensus) UpdatePublicKeys(pubKeys []*bls.PublicKey) int {
 		consensus.CommitteePublicKeys[pubKey.SerializeToHexStr()] = true
 	}
 	// TODO: use pubkey to identify leader rather than p2p.Peer.
-	consensus.leader = p2p.Peer{ConsensusPubKey: pubKeys[0]}
-	consensus.LeaderPubKey = pubKeys[0]
+	consensus.setLeaderPubKey(pubKeys[0])
 
-	utils.Logger().Info().Str("info", consensus.LeaderPubKey.SerializeToHexStr()).Msg("My Leader")
+	utils.Logger().Info().Str("info", consensus.GetLeaderPubKey().SerializeToHexStr()).Msg("My Leader")
 	consensus.pubKeyLock.Unlock()
 	// reset states after update public keys
 	consensus.ResetState()
 	consensus.ResetViewChangeState()
 
-	return len(consensus.PublicKeys)
+	return len(consensus.PublicKeys), nil
+}
+
+// validatePublicKeys checks that pubKeys can form a committee, which would
+// otherwise panic or build a bitmap counting a validator twice
+func validatePublicKeys(pubKeys []*bls.PublicKey) error {
+	if len(pubKeys) == 0 {
+		return errors.New("empty committee")
+	}
+	seen := map[string]bool{}
+	for i, pubKey := range pubKeys {
+		if pubKey == nil {
+			return ctxerror.New("nil public key in committee", "index", i)
+		}
+		key := pubKey.SerializeToHexStr()
+		if seen[key] {
+			return ctxerror.New("duplicate public key in committee", "index", i, "key", key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// CommitteeSnapshot returns a copy of the committee's public keys, in the
+// order their bits appear in the signature bitmaps.
+func (consensus *Consensus) CommitteeSnapshot() []*bls.PublicKey {
+	consensus.pubKeyLock.Lock()
+	defer consensus.pubKeyLock.Unlock()
+	return append([]*bls.PublicKey(nil), consensus.PublicKeys...)
+}
+
+// DiffCommittees returns the keys of newKeys that are not in oldKeys, and the
+// keys of oldKeys that are not in newKeys.
+func DiffCommittees(oldKeys, newKeys []*bls.PublicKey) (added, removed []*bls.PublicKey) {
+	inOld := map[string]bool{}
+	for _, key := range oldKeys {
+		inOld[key.SerializeToHexStr()] = true
+	}
+	inNew := map[string]bool{}
+	for _, key := range newKeys {
+		inNew[key.SerializeToHexStr()] = true
+		if !inOld[key.SerializeToHexStr()] {
+			added = append(added, key)
+		}
+	}
+	for _, key := range oldKeys {
+		if !inNew[key.SerializeToHexStr()] {
+			removed = append(removed, key)
+		}
+	}
+	return added, removed
 }
 
 // NewFaker returns a faker consensus.
@@ -175,18 +263,31 @@ func (consensus *Consensus) signConsensusMessage(message *msg_pb.Message) error
 	return nil
 }
 
-// GetValidatorPeers returns list of validator peers.
+// GetValidatorPeers returns list of validator peers, sorted by BLS public key
+// so that every call returns them in the same order.
 func (consensus *Consensus) GetValidatorPeers() []p2p.Peer {
-	validatorPeers := make([]p2p.Peer, 0)
+	type keyedPeer struct {
+		key  string
+		peer p2p.Peer
+	}
+	keyedPeers := []keyedPeer{}
 
 	consensus.validators.Range(func(k, v interface{}) bool {
 		if peer, ok := v.(p2p.Peer); ok {
-			validatorPeers = append(validatorPeers, peer)
+			key, _ := k.(string)
+			keyedPeers = append(keyedPeers, keyedPeer{key: key, peer: peer})
 			return true
 		}
 		return false
 	})
+	sort.Slice(keyedPeers, func(i, j int) bool {
+		return keyedPeers[i].key < keyedPeers[j].key
+	})
 
+	validatorPeers := make([]p2p.Peer, 0, len(keyedPeers))
+	for _, keyed := range keyedPeers {
+		validatorPeers = append(validatorPeers, keyed.peer)
+	}
 	return validatorPeers
 }
 
@@ -246,6 +347,8 @@ func (consensus *Consensus) ResetState() {
 	consensus.block = []byte{}
 	consensus.prepareSigs = map[string]*bls.Sign{}
 	consensus.commitSigs = map[string]*bls.Sign{}
+	consensus.roundLatencies = map[string]time.Duration{}
+	consensus.announceTime = time.Time{}
 
 	prepareBitmap, _ := bls_cosi.NewMask(consensus.PublicKeys, nil)
 	commitBitmap, _ := bls_cosi.NewMask(consensus.PublicKeys, nil)
@@ -296,21 +399,168 @@ func verifyMessageSig(signerPubKey *bls.PublicKey, message *msg_pb.Message) erro
 	}
 	msgHash := hash.Keccak256(messageBytes)
 	if !msgSig.VerifyHash(signerPubKey, msgHash[:]) {
-		return errors.New("failed to verify the signature")
+		return ErrInvalidSignature
 	}
 	message.Signature = signature
 	return nil
 }
 
+// verifyCommittedSig verifies the aggregated commit signature and bitmap
+// carried in the payload of a committed message, and returns the mask of
+// the validators who signed.
+func (consensus *Consensus) verifyCommittedSig(msg *PbftMessage) (*bls_cosi.Mask, error) {
+	aggSig, mask, err := consensus.ReadSignatureBitmapPayload(msg.Payload, 0)
+	if err != nil {
+		return nil, err
+	}
+	if count := utils.CountOneBits(mask.Bitmap); !consensus.HasQuorum(count) {
+		return nil, ErrQuorumNotReached
+	}
+	commitPayload := ConstructCommitPayload(msg.BlockNum, msg.BlockHash)
+	if !aggSig.VerifyHash(mask.AggregatePublic, commitPayload) {
+		return nil, ErrInvalidSignature
+	}
+	return mask, nil
+}
+
+// checkBitmapMatchesSigs verifies that the bits set in mask are exactly the
+// validators with a signature in sigs, so that an aggregated signature and
+// the bitmap sent along with it describe the same signers.
+func checkBitmapMatchesSigs(sigs map[string]*bls.Sign, mask *bls_cosi.Mask) error {
+	signers := mask.GetPubKeyFromMask(true)
+	if len(signers) != len(sigs) {
+		return ctxerror.New("bitmap does not match signatures",
+			"bitsSet", len(signers),
+			"signatures", len(sigs))
+	}
+	for _, key := range signers {
+		if _, ok := sigs[key.SerializeToHexStr()]; !ok {
+			return ctxerror.New("bit set for a validator without a signature",
+				"validator", key.SerializeToHexStr())
+		}
+	}
+	return nil
+}
+
+// setLastSigners records which validators signed the block just committed.
+func (consensus *Consensus) setLastSigners(mask *bls_cosi.Mask) {
+	signers := make([]bool, mask.CountTotal())
+	for i := range signers {
+		signers[i], _ = mask.IndexEnabled(i)
+	}
+	consensus.lastSignersMutex.Lock()
+	consensus.lastSigners = signers
+	consensus.lastSignersMutex.Unlock()
+}
+
+// SignerBitmap returns, for each validator in PublicKeys order, whether it
+// signed the last committed block.  It is nil until a block is committed.
+func (consensus *Consensus) SignerBitmap() []bool {
+	consensus.lastSignersMutex.Lock()
+	defer consensus.lastSignersMutex.Unlock()
+	return append([]bool(nil), consensus.lastSigners...)
+}
+
+// SignerCount returns how many validators signed the last committed block.
+func (consensus *Consensus) SignerCount() int {
+	consensus.lastSignersMutex.Lock()
+	defer consensus.lastSignersMutex.Unlock()
+	count := 0
+	for _, signed := range consensus.lastSigners {
+		if signed {
+			count++
+		}
+	}
+	return count
+}
+
+// ConstructCommitPayload returns the bytes validators sign in the commit
+// phase: the block number as 8 bytes little endian, followed by the block hash.
+func ConstructCommitPayload(blockNum uint64, blockHash common.Hash) []byte {
+	blockNumBytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(blockNumBytes, blockNum)
+	return append(blockNumBytes, blockHash[:]...)
+}
+
+// verifyBlockLimits checks the encoded size and the transaction count of
+// a proposed block against MinBlockBytes, MaxBlockBytes and MaxTxCount.
+func (consensus *Consensus) verifyBlockLimits(encodedBlock []byte, block *types.Block) error {
+	if consensus.MinBlockBytes > 0 && len(encodedBlock) < consensus.MinBlockBytes {
+		return ctxerror.New("block too small",
+			"size", len(encodedBlock),
+			"minSize", consensus.MinBlockBytes)
+	}
+	if consensus.MaxBlockBytes > 0 && len(encodedBlock) > consensus.MaxBlockBytes {
+		return ctxerror.New("block too large",
+			"size", len(encodedBlock),
+			"maxSize", consensus.MaxBlockBytes)
+	}
+	if numTxs := len(block.Transactions()); consensus.MaxTxCount > 0 && numTxs > consensus.MaxTxCount {
+		return ctxerror.New("too many transactions in block",
+			"numTxs", numTxs,
+			"maxTxs", consensus.MaxTxCount)
+	}
+	return nil
+}
+
+// recordBlockLimitViolation counts a proposal of the leader that violated the
+// block limits and returns how many of its proposals did so far.
+func (consensus *Consensus) recordBlockLimitViolation(leaderPubKey *bls.PublicKey) uint64 {
+	consensus.blockLimitViolationsMutex.Lock()
+	defer consensus.blockLimitViolationsMutex.Unlock()
+	if consensus.blockLimitViolations == nil {
+		consensus.blockLimitViolations = map[string]uint64{}
+	}
+	consensus.blockLimitViolations[leaderPubKey.SerializeToHexStr()]++
+	return consensus.blockLimitViolations[leaderPubKey.SerializeToHexStr()]
+}
+
+// BlockLimitViolations returns how many proposals of the given leader this
+// validator rejected for violating the block limits.
+func (consensus *Consensus) BlockLimitViolations(leaderPubKey *bls.PublicKey) uint64 {
+	consensus.blockLimitViolationsMutex.Lock()
+	defer consensus.blockLimitViolationsMutex.Unlock()
+	return consensus.blockLimitViolations[leaderPubKey.SerializeToHexStr()]
+}
+
+// verifyBlockTime checks that the header's timestamp is within
+// maxBlockTimeDrift of now. A zero drift disables the check.
+func (consensus *Consensus) verifyBlockTime(header *types.Header, now time.Time) error {
+	if consensus.maxBlockTimeDrift == 0 {
+		return nil
+	}
+	if header.Time == nil {
+		return ctxerror.New("block header has no timestamp")
+	}
+	blockTime := time.Unix(header.Time.Int64(), 0)
+	drift := blockTime.Sub(now)
+	if drift < 0 {
+		drift = -drift
+	}
+	if drift > consensus.maxBlockTimeDrift {
+		return ctxerror.New("block timestamp too far from local clock",
+			"blockTime", blockTime,
+			"localTime", now,
+			"maxDrift", consensus.maxBlockTimeDrift)
+	}
+	return nil
+}
+
 // verifySenderKey verifys the message senderKey is properly signed and senderAddr is valid
 func (consensus *Consensus) verifySenderKey(msg *msg_pb.Message) (*bls.PublicKey, error) {
 	consensusMsg := msg.GetConsensus()
+	if consensusMsg == nil {
+		atomic.AddUint64(&consensus.messagesRejected, 1)
+		return nil, errors.New("missing consensus request")
+	}
 	senderKey, err := bls_cosi.BytesToBlsPublicKey(consensusMsg.SenderPubkey)
 	if err != nil {
+		atomic.AddUint64(&consensus.messagesRejected, 1)
 		return nil, err
 	}
 
 	if !consensus.IsValidatorInCommittee(senderKey) {
+		atomic.AddUint64(&consensus.messagesRejected, 1)
 		return nil, fmt.Errorf("Validator %s is not in committee", senderKey.SerializeToHexStr())
 	}
 	return senderKey, nil
@@ -318,12 +568,18 @@ func (consensus *Consensus) verifySenderKey(msg *msg_pb.Message) (*bls.PublicKey
 
 func (consensus *Consensus) verifyViewChangeSenderKey(msg *msg_pb.Message) (*bls.PublicKey, error) {
 	vcMsg := msg.GetViewchange()
+	if vcMsg == nil {
+		atomic.AddUint64(&consensus.messagesRejected, 1)
+		return nil, errors.New("missing viewchange request")
+	}
 	senderKey, err := bls_cosi.BytesToBlsPublicKey(vcMsg.SenderPubkey)
 	if err != nil {
+		atomic.AddUint64(&consensus.messagesRejected, 1)
 		return nil, err
 	}
 
 	if !consensus.IsValidatorInCommittee(senderKey) {
+		atomic.AddUint64(&consensus.messagesRejected, 1)
 		return nil, fmt.Errorf("Validator %s is not in committee", senderKey.SerializeToHexStr())
 	}
 	return senderKey, nil
@@ -334,6 +590,37 @@ func (consensus *Consensus) SetViewID(height uint64) {
 	consensus.viewID = height
 }
 
+// ForceView moves this node to viewID to recover a shard stuck on different
+// views.  Only meant for operators: it refuses to move to an older view unless
+// allowBackward is set, and to interrupt a round past the announce phase.
+// The leader is the one picked for viewID; if it doesn't propose in the new
+// view, the consensus timeout starts a view change from there.
+func (consensus *Consensus) ForceView(viewID uint64, allowBackward bool) error {
+	consensus.mutex.Lock()
+	defer consensus.mutex.Unlock()
+
+	if viewID < consensus.viewID && !allowBackward {
+		return ErrStaleView
+	}
+	if consensus.phase != Announce && consensus.mode.Mode() != ViewChanging {
+		return ctxerror.New("cannot force view in the middle of a round",
+			"phase", consensus.phase,
+			"viewID", consensus.viewID)
+	}
+	consensus.getLogger().Warn().
+		Uint64("fromViewID", consensus.viewID).
+		Uint64("toViewID", viewID).
+		Msg("[ForceView] Forcing view change by operator")
+	consensus.viewID = viewID
+	consensus.mode.SetViewID(viewID)
+	consensus.setLeaderPubKey(consensus.GetNextLeaderKey(viewID))
+	consensus.ResetState()
+	consensus.ResetViewChangeState()
+	consensus.consensusTimeout[timeoutViewChange].Stop()
+	consensus.consensusTimeout[timeoutConsensus].Start()
+	return nil
+}
+
 // SetMode sets the mode of consensus
 func (consensus *Consensus) SetMode(mode Mode) {
 	consensus.mode.SetMode(mode)
@@ -363,12 +650,12 @@ func (consensus *Consensus) checkViewID(msg *PbftMessage) error {
 		consensus.mode.SetMode(Normal)
 		consensus.viewID = msg.ViewID
 		consensus.mode.SetViewID(msg.ViewID)
-		consensus.LeaderPubKey = msg.SenderPubkey
+		consensus.setLeaderPubKey(msg.SenderPubkey)
 		consensus.ignoreViewIDCheck = false
 		consensus.consensusTimeout[timeoutConsensus].Start()
 		utils.Logger().Debug().
 			Uint64("viewID", consensus.viewID).
-			Str("leaderKey", consensus.LeaderPubKey.SerializeToHexStr()[:20]).
+			Str("leaderKey", consensus.GetLeaderPubKey().SerializeToHexStr()[:20]).
 			Msg("viewID and leaderKey override")
 		utils.Logger().Debug().
 			Uint64("viewID", consensus.viewID).
@@ -378,7 +665,7 @@ func (consensus *Consensus) checkViewID(msg *PbftMessage) error {
 	} else if msg.ViewID > consensus.viewID {
 		return consensus_engine.ErrViewIDNotMatch
 	} else if msg.ViewID < consensus.viewID {
-		return errors.New("view ID belongs to the past")
+		return ErrStaleView
 	}
 	return nil
 }
@@ -445,7 +732,13 @@ func (consensus *Consensus) reportMetrics(block types.Block) {
 
 // getLogger returns logger for consensus contexts added
 func (consensus *Consensus) getLogger() *zerolog.Logger {
-	logger := utils.Logger().With().
+	base := utils.Logger()
+	if consensus.logLevel != nil {
+		filtered := base.Level(*consensus.logLevel)
+		base = &filtered
+	}
+	logger := base.With().
+		Uint32("myShardID", consensus.ShardID).
 		Uint64("myEpoch", consensus.epoch).
 		Uint64("myBlock", consensus.blockNum).
 		Uint64("myViewID", consensus.viewID).
@@ -530,7 +823,10 @@ func (consensus *Consensus) UpdateConsensusInformation() Mode {
 	consensus.getLogger().Info().
 		Int("numPubKeys", len(pubKeys)).
 		Msg("[UpdateConsensusInformation] Successfully updated public keys")
-	consensus.UpdatePublicKeys(pubKeys)
+	if _, err := consensus.UpdatePublicKeys(pubKeys); err != nil {
+		consensus.getLogger().Error().Err(err).Msg("[UpdateConsensusInformation] Cannot update public keys")
+		hasError = true
+	}
 
 	// take care of possible leader change during the epoch
 	if !core.IsEpochLastBlockByHeader(header) && header.Number.Uint64() != 0 {
@@ -543,7 +839,7 @@ func (consensus *Consensus) UpdateConsensusInformation() Mode {
 			consensus.getLogger().Debug().
 				Str("leaderPubKey", leaderPubKey.SerializeToHexStr()).
 				Msg("[SYNC] Most Recent LeaderPubKey Updated Based on BlockChain")
-			consensus.LeaderPubKey = leaderPubKey
+			consensus.setLeaderPubKey(leaderPubKey)
 		}
 	}
 
@@ -563,8 +859,9 @@ func (consensus *Consensus) UpdateConsensusInformation() Mode {
 // IsLeader check if the node is a leader or not by comparing the public key of
 // the node with the leader public key
 func (consensus *Consensus) IsLeader() bool {
-	if consensus.PubKey != nil && consensus.LeaderPubKey != nil {
-		return consensus.PubKey.IsEqual(consensus.LeaderPubKey)
+	leaderPubKey := consensus.GetLeaderPubKey()
+	if consensus.PubKey != nil && leaderPubKey != nil {
+		return consensus.PubKey.IsEqual(leaderPubKey)
 	}
 	return false
 }
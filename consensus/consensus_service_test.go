@@ -2,8 +2,17 @@ package consensus
 
 import (
 	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math/big"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	ffi_bls "github.com/harmony-one/bls/ffi/go/bls"
+
+	"github.com/harmony-one/harmony/core/types"
 	"github.com/harmony-one/harmony/crypto/bls"
 
 	msg_pb "github.com/harmony-one/harmony/api/proto/message"
@@ -90,3 +99,401 @@ func TestSetViewID(t *testing.T) {
 		t.Errorf("Cannot set consensus ID. Got: %v, Expected: %v", consensus.viewID, height)
 	}
 }
+
+func TestVerifyCommittedSig(t *testing.T) {
+	consensus := &Consensus{}
+	_, peers, priKeys := NewTestCommittee(4)
+	for _, peer := range peers {
+		consensus.PublicKeys = append(consensus.PublicKeys, peer.ConsensusPubKey)
+	}
+
+	blockNum := uint64(10)
+	blockHash := common.Hash{0x01, 0x02}
+	blockNumBytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(blockNumBytes, blockNum)
+	commitPayload := append(blockNumBytes, blockHash[:]...)
+
+	makePayload := func(signers int) []byte {
+		mask, _ := bls.NewMask(consensus.PublicKeys, nil)
+		sigs := []*ffi_bls.Sign{}
+		for i := 0; i < signers; i++ {
+			sigs = append(sigs, priKeys[i].SignHash(commitPayload))
+			mask.SetKey(consensus.PublicKeys[i], true)
+		}
+		return append(bls.AggregateSig(sigs).Serialize(), mask.Bitmap...)
+	}
+
+	msg := &PbftMessage{BlockNum: blockNum, BlockHash: blockHash, Payload: makePayload(3)}
+	if _, err := consensus.verifyCommittedSig(msg); err != nil {
+		t.Errorf("Valid committed signature failed verification: %s", err)
+	}
+
+	msg = &PbftMessage{BlockNum: blockNum, BlockHash: common.Hash{0x03}, Payload: makePayload(3)}
+	if _, err := consensus.verifyCommittedSig(msg); err != ErrInvalidSignature {
+		t.Errorf("Committed signature on another block hash should not verify, got %v", err)
+	}
+
+	msg = &PbftMessage{BlockNum: blockNum, BlockHash: blockHash, Payload: makePayload(2)}
+	if _, err := consensus.verifyCommittedSig(msg); err != ErrQuorumNotReached {
+		t.Errorf("Committed signature without quorum should not verify, got %v", err)
+	}
+}
+
+func TestCheckBitmapMatchesSigs(t *testing.T) {
+	_, peers, priKeys := NewTestCommittee(4)
+	publicKeys := []*ffi_bls.PublicKey{}
+	for _, peer := range peers {
+		publicKeys = append(publicKeys, peer.ConsensusPubKey)
+	}
+	mask, _ := bls.NewMask(publicKeys, nil)
+	sigs := map[string]*ffi_bls.Sign{}
+	for i := 0; i < 2; i++ {
+		sigs[publicKeys[i].SerializeToHexStr()] = priKeys[i].SignHash([]byte("payload"))
+		mask.SetKey(publicKeys[i], true)
+	}
+	if err := checkBitmapMatchesSigs(sigs, mask); err != nil {
+		t.Errorf("Matching bitmap and signatures rejected: %s", err)
+	}
+
+	mask.SetKey(publicKeys[2], true)
+	if err := checkBitmapMatchesSigs(sigs, mask); err == nil {
+		t.Error("Bit set without a signature should be rejected")
+	}
+
+	mask.SetKey(publicKeys[2], false)
+	mask.SetKey(publicKeys[1], false)
+	mask.SetKey(publicKeys[3], true)
+	if err := checkBitmapMatchesSigs(sigs, mask); err == nil {
+		t.Error("Bit set for a different validator than the one signed should be rejected")
+	}
+
+	mask.SetKey(publicKeys[3], false)
+	if err := checkBitmapMatchesSigs(sigs, mask); err == nil {
+		t.Error("Signature without its bit set should be rejected")
+	}
+}
+
+func TestForceView(t *testing.T) {
+	leader := p2p.Peer{IP: "127.0.0.1", Port: "9902"}
+	priKey, _, _ := utils.GenKeyP2P("127.0.0.1", "9902")
+	host, err := p2pimpl.NewHost(&leader, priKey)
+	if err != nil {
+		t.Fatalf("newhost failure: %v", err)
+	}
+	consensus, err := New(host, 0, leader, bls.RandPrivateKey())
+	if err != nil {
+		t.Fatalf("Cannot craeate consensus: %v", err)
+	}
+	_, peers, _ := NewTestCommittee(5)
+	pubKeys := []*ffi_bls.PublicKey{}
+	for _, peer := range peers {
+		pubKeys = append(pubKeys, peer.ConsensusPubKey)
+	}
+	consensus.UpdatePublicKeys(pubKeys)
+	consensus.SetLeaderSelector(viewLeaderSelector{})
+	consensus.viewID = 10
+
+	if err := consensus.ForceView(5, false); err != ErrStaleView {
+		t.Errorf("Expected ErrStaleView moving backward without override, got %v", err)
+	}
+	if !consensus.GetLeaderPubKey().IsEqual(pubKeys[0]) {
+		t.Error("Refused view change should keep the leader")
+	}
+	if err := consensus.ForceView(12, false); err != nil || consensus.viewID != 12 || consensus.mode.ViewID() != 12 {
+		t.Errorf("Expected view 12, got %d (%v)", consensus.viewID, err)
+	}
+	if !consensus.GetLeaderPubKey().IsEqual(pubKeys[2]) {
+		t.Error("Leader should be the one of view 12")
+	}
+
+	consensus.switchPhase(Prepare, true)
+	if err := consensus.ForceView(13, false); err == nil || consensus.viewID != 12 {
+		t.Error("Forcing the view in the middle of a round should be refused")
+	}
+
+	consensus.ResetState()
+	if err := consensus.ForceView(5, true); err != nil || consensus.viewID != 5 {
+		t.Errorf("Expected view 5 with override, got %d (%v)", consensus.viewID, err)
+	}
+	if !consensus.GetLeaderPubKey().IsEqual(pubKeys[0]) {
+		t.Error("Leader should be the one of view 5")
+	}
+}
+
+// viewLeaderSelector picks the leader by view ID so tests can tell which view
+// a leader was chosen for.
+type viewLeaderSelector struct{}
+
+func (viewLeaderSelector) NextLeader(publicKeys []*ffi_bls.PublicKey, currentLeader *ffi_bls.PublicKey, viewID uint64) *ffi_bls.PublicKey {
+	return publicKeys[viewID%uint64(len(publicKeys))]
+}
+
+func TestCheckViewID(t *testing.T) {
+	consensus := &Consensus{viewID: 5}
+	if err := consensus.checkViewID(&PbftMessage{ViewID: 5}); err != nil {
+		t.Errorf("Message for the current view should be accepted: %v", err)
+	}
+	if err := consensus.checkViewID(&PbftMessage{ViewID: 4}); err != ErrStaleView {
+		t.Errorf("Expected ErrStaleView for a past view, got %v", err)
+	}
+	if err := consensus.checkViewID(&PbftMessage{ViewID: 6}); err == nil || err == ErrStaleView {
+		t.Errorf("Message for a future view should be rejected as not matching, got %v", err)
+	}
+}
+
+func TestSignerBitmap(t *testing.T) {
+	consensus := &Consensus{}
+	_, peers, priKeys := NewTestCommittee(4)
+	for _, peer := range peers {
+		consensus.PublicKeys = append(consensus.PublicKeys, peer.ConsensusPubKey)
+	}
+	if consensus.SignerCount() != 0 || consensus.SignerBitmap() != nil {
+		t.Error("Expected no signers before any block is committed")
+	}
+
+	blockNum := uint64(10)
+	blockHash := common.Hash{0x01, 0x02}
+	commitPayload := ConstructCommitPayload(blockNum, blockHash)
+	mask, _ := bls.NewMask(consensus.PublicKeys, nil)
+	sigs := []*ffi_bls.Sign{}
+	for _, i := range []int{0, 2, 3} {
+		sigs = append(sigs, priKeys[i].SignHash(commitPayload))
+		mask.SetKey(consensus.PublicKeys[i], true)
+	}
+	msg := &PbftMessage{
+		BlockNum:  blockNum,
+		BlockHash: blockHash,
+		Payload:   append(bls.AggregateSig(sigs).Serialize(), mask.Bitmap...),
+	}
+	signers, err := consensus.verifyCommittedSig(msg)
+	if err != nil {
+		t.Fatalf("Valid committed signature failed verification: %s", err)
+	}
+	consensus.setLastSigners(signers)
+
+	if count := consensus.SignerCount(); count != len(sigs) {
+		t.Errorf("Expected %d signers, got %d", len(sigs), count)
+	}
+	expected := []bool{true, false, true, true}
+	bitmap := consensus.SignerBitmap()
+	if len(bitmap) != len(expected) {
+		t.Fatalf("Expected bitmap of %d validators, got %d", len(expected), len(bitmap))
+	}
+	for i := range expected {
+		if bitmap[i] != expected[i] {
+			t.Errorf("Validator %d: expected signed=%v, got %v", i, expected[i], bitmap[i])
+		}
+	}
+}
+
+func TestVerifySenderKey(t *testing.T) {
+	consensus := &Consensus{}
+	member := bls.RandPrivateKey().GetPublicKey()
+	consensus.UpdatePublicKeys([]*ffi_bls.PublicKey{member})
+
+	msg := &msg_pb.Message{
+		Request: &msg_pb.Message_Consensus{
+			Consensus: &msg_pb.ConsensusRequest{SenderPubkey: member.Serialize()},
+		},
+	}
+	if _, err := consensus.verifySenderKey(msg); err != nil {
+		t.Errorf("Committee member should be accepted: %s", err)
+	}
+
+	stranger := bls.RandPrivateKey().GetPublicKey()
+	msg.GetConsensus().SenderPubkey = stranger.Serialize()
+	if _, err := consensus.verifySenderKey(msg); err == nil {
+		t.Error("Sender outside of the committee should be rejected")
+	}
+	if _, err := consensus.verifySenderKey(&msg_pb.Message{Type: msg_pb.MessageType_PREPARE}); err == nil {
+		t.Error("Message without consensus request should be rejected")
+	}
+	if consensus.MessagesRejected() != 2 {
+		t.Errorf("Expected 2 rejected messages, got %d", consensus.MessagesRejected())
+	}
+}
+
+func TestVerifyBlockTime(t *testing.T) {
+	consensus := &Consensus{}
+	now := time.Unix(1000000, 0)
+	header := &types.Header{Time: big.NewInt(now.Unix() - 3600)}
+	if err := consensus.verifyBlockTime(header, now); err != nil {
+		t.Errorf("Block time should not be checked when disabled: %s", err)
+	}
+
+	consensus.SetMaxBlockTimeDrift(time.Minute)
+	for _, offset := range []int64{-60, 0, 60} {
+		header.Time = big.NewInt(now.Unix() + offset)
+		if err := consensus.verifyBlockTime(header, now); err != nil {
+			t.Errorf("Block time %ds from now should be accepted: %s", offset, err)
+		}
+	}
+	for _, offset := range []int64{-61, 61} {
+		header.Time = big.NewInt(now.Unix() + offset)
+		if err := consensus.verifyBlockTime(header, now); err == nil {
+			t.Errorf("Block time %ds from now should be rejected", offset)
+		}
+	}
+}
+
+func TestVerifyBlockLimits(t *testing.T) {
+	consensus := &Consensus{}
+	txs := []*types.Transaction{}
+	for i := 0; i < 3; i++ {
+		txs = append(txs, types.NewTransaction(uint64(i), common.Address{}, 0, big.NewInt(0), 0, big.NewInt(0), nil))
+	}
+	block := types.NewBlock(&types.Header{Number: big.NewInt(1)}, txs, nil, nil, nil)
+	encodedBlock := make([]byte, 1000)
+	if err := consensus.verifyBlockLimits(encodedBlock, block); err != nil {
+		t.Errorf("Block should be accepted without limits: %s", err)
+	}
+
+	consensus.MaxBlockBytes = 1000
+	consensus.MaxTxCount = 3
+	if err := consensus.verifyBlockLimits(encodedBlock, block); err != nil {
+		t.Errorf("Block at the limits should be accepted: %s", err)
+	}
+
+	consensus.MaxBlockBytes = 999
+	if err := consensus.verifyBlockLimits(encodedBlock, block); err == nil {
+		t.Error("Block over the size limit should be rejected")
+	}
+
+	consensus.MaxBlockBytes = 0
+	consensus.MaxTxCount = 2
+	if err := consensus.verifyBlockLimits(encodedBlock, block); err == nil {
+		t.Error("Block over the transaction limit should be rejected")
+	}
+
+	consensus.MaxTxCount = 0
+	consensus.MinBlockBytes = 1000
+	if err := consensus.verifyBlockLimits(encodedBlock, block); err != nil {
+		t.Errorf("Block at the minimum size should be accepted: %s", err)
+	}
+	consensus.MinBlockBytes = 1001
+	if err := consensus.verifyBlockLimits(encodedBlock, block); err == nil {
+		t.Error("Block under the minimum size should be rejected")
+	}
+}
+
+func TestGetValidatorPeersSorted(t *testing.T) {
+	consensus := &Consensus{}
+	_, peers, _ := NewTestCommittee(8)
+	for _, peer := range peers {
+		consensus.validators.Store(peer.ConsensusPubKey.SerializeToHexStr(), peer)
+	}
+
+	first := consensus.GetValidatorPeers()
+	if len(first) != len(peers) {
+		t.Fatalf("Expected %d peers, got %d", len(peers), len(first))
+	}
+	for i := 1; i < len(first); i++ {
+		if first[i-1].ConsensusPubKey.SerializeToHexStr() >= first[i].ConsensusPubKey.SerializeToHexStr() {
+			t.Errorf("Peers are not sorted by public key at index %d", i)
+		}
+	}
+	second := consensus.GetValidatorPeers()
+	for i := range first {
+		if !first[i].ConsensusPubKey.IsEqual(second[i].ConsensusPubKey) {
+			t.Errorf("Peer order changed between calls at index %d", i)
+		}
+	}
+}
+
+func TestCurrentLeader(t *testing.T) {
+	consensus := &Consensus{}
+	_, peers, _ := NewTestCommittee(2)
+	consensus.validators.Store(peers[0].ConsensusPubKey.SerializeToHexStr(), peers[0])
+
+	consensus.UpdatePublicKeys([]*ffi_bls.PublicKey{peers[0].ConsensusPubKey, peers[1].ConsensusPubKey})
+	if leader := consensus.CurrentLeader(); leader.Port != peers[0].Port || !leader.ConsensusPubKey.IsEqual(peers[0].ConsensusPubKey) {
+		t.Errorf("Expected the first committee member as leader, got %s", leader)
+	}
+
+	// e.g. elected by a view change, not a known validator peer
+	consensus.setLeaderPubKey(peers[1].ConsensusPubKey)
+	leader := consensus.CurrentLeader()
+	if !leader.ConsensusPubKey.IsEqual(peers[1].ConsensusPubKey) || !consensus.GetLeaderPubKey().IsEqual(peers[1].ConsensusPubKey) {
+		t.Errorf("Leader was not updated, got %s", leader)
+	}
+	if leader.Port != "" {
+		t.Errorf("Unknown leader peer should only carry its key, got %s", leader)
+	}
+}
+
+func TestUpdatePublicKeysValidation(t *testing.T) {
+	consensus := &Consensus{}
+	_, peers, _ := NewTestCommittee(2)
+	key0, key1 := peers[0].ConsensusPubKey, peers[1].ConsensusPubKey
+	if n, err := consensus.UpdatePublicKeys([]*ffi_bls.PublicKey{key0, key1}); err != nil || n != 2 {
+		t.Fatalf("Valid committee should be accepted, got %d, %v", n, err)
+	}
+
+	tests := map[string][]*ffi_bls.PublicKey{
+		"empty":     {},
+		"nil key":   {key0, nil},
+		"duplicate": {key0, key1, key0},
+	}
+	for name, pubKeys := range tests {
+		n, err := consensus.UpdatePublicKeys(pubKeys)
+		if err == nil {
+			t.Errorf("%s committee should be rejected", name)
+		}
+		if n != 2 || len(consensus.PublicKeys) != 2 {
+			t.Errorf("%s committee should leave the committee unchanged, got %d keys", name, n)
+		}
+	}
+}
+
+func TestDiffCommittees(t *testing.T) {
+	consensus := &Consensus{}
+	_, peers, _ := NewTestCommittee(4)
+	keys := []*ffi_bls.PublicKey{}
+	for _, peer := range peers {
+		keys = append(keys, peer.ConsensusPubKey)
+	}
+	consensus.UpdatePublicKeys(keys[:3])
+	before := consensus.CommitteeSnapshot()
+	consensus.UpdatePublicKeys([]*ffi_bls.PublicKey{keys[0], keys[2], keys[3]})
+	after := consensus.CommitteeSnapshot()
+
+	if len(before) != 3 || !before[1].IsEqual(keys[1]) {
+		t.Fatal("Snapshot should not change when the committee is updated")
+	}
+	added, removed := DiffCommittees(before, after)
+	if len(added) != 1 || !added[0].IsEqual(keys[3]) {
+		t.Errorf("Expected validator 3 to be added, got %d keys", len(added))
+	}
+	if len(removed) != 1 || !removed[0].IsEqual(keys[1]) {
+		t.Errorf("Expected validator 1 to be removed, got %d keys", len(removed))
+	}
+
+	added, removed = DiffCommittees(after, after)
+	if len(added) != 0 || len(removed) != 0 {
+		t.Error("Identical committees should have no difference")
+	}
+}
+
+func TestConstructCommitPayload(t *testing.T) {
+	blockHash := common.Hash{0xaa, 0xbb}
+	payload := ConstructCommitPayload(258, blockHash)
+	if !bytes.Equal(payload, ConstructCommitPayload(258, blockHash)) {
+		t.Error("Commit payload is not deterministic")
+	}
+	if len(payload) != 8+len(blockHash) {
+		t.Fatalf("Unexpected commit payload length %d", len(payload))
+	}
+	if binary.LittleEndian.Uint64(payload[:8]) != 258 || !bytes.Equal(payload[8:], blockHash[:]) {
+		t.Errorf("Unexpected commit payload layout %x", payload)
+	}
+	if bytes.Equal(payload, ConstructCommitPayload(259, blockHash)) {
+		t.Error("Commit payloads of different blocks should differ")
+	}
+
+	// Known-good bytes: the chain's VerifySeal rebuilds the same layout, so
+	// changing the byte order would invalidate every signature on chain.
+	expected, _ := hex.DecodeString("0201000000000000" + "aabb" + strings.Repeat("00", 30))
+	if !bytes.Equal(payload, expected) {
+		t.Errorf("Commit payload %x does not match test vector %x", payload, expected)
+	}
+}
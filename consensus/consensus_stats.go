@@ -0,0 +1,190 @@
+package consensus
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/harmony-one/bls/ffi/go/bls"
+)
+
+// latencyEMAWeight is the weight of the newest sample in the moving average
+// of the validator latencies, so a transient spike doesn't dominate.
+const latencyEMAWeight = 0.2
+
+// roundEstimatePercentile is the percentile of the validator latencies used by
+// EstimatedRoundDuration, high enough that a few slow validators count.
+const roundEstimatePercentile = 0.95
+
+// ConsensusStats is a snapshot of the consensus progress, e.g. for monitoring.
+type ConsensusStats struct {
+	ShardID  uint32
+	Phase    PbftPhase
+	Mode     Mode
+	ViewID   uint64
+	BlockNum uint64
+	IsLeader bool
+	// Number of prepare/commit signatures collected so far in this round (leader only)
+	NumPrepareSigs int
+	NumCommitSigs  int
+	// Number of blocks committed by this node since it started
+	RoundsCompleted uint64
+	// Average time between two consecutive committed blocks
+	AverageRoundDuration time.Duration
+	// Time between announce and each validator's prepare message in this
+	// round, and its moving average across rounds, keyed by BLS public key (leader only)
+	RoundLatencies   map[string]time.Duration
+	AverageLatencies map[string]time.Duration
+}
+
+// Stats returns a snapshot of the current consensus progress.
+func (consensus *Consensus) Stats() ConsensusStats {
+	consensus.mutex.Lock()
+	defer consensus.mutex.Unlock()
+
+	stats := ConsensusStats{
+		ShardID:         consensus.ShardID,
+		Phase:           consensus.phase,
+		Mode:            consensus.mode.Mode(),
+		ViewID:          consensus.viewID,
+		BlockNum:        consensus.blockNum,
+		IsLeader:        consensus.IsLeader(),
+		NumPrepareSigs:  len(consensus.prepareSigs),
+		NumCommitSigs:   len(consensus.commitSigs),
+		RoundsCompleted: consensus.roundsCompleted,
+	}
+	stats.RoundLatencies = make(map[string]time.Duration, len(consensus.roundLatencies))
+	for k, v := range consensus.roundLatencies {
+		stats.RoundLatencies[k] = v
+	}
+	stats.AverageLatencies = make(map[string]time.Duration, len(consensus.avgLatencies))
+	for k, v := range consensus.avgLatencies {
+		stats.AverageLatencies[k] = v
+	}
+	if consensus.roundsCompleted > 1 {
+		stats.AverageRoundDuration = consensus.totalRoundDuration / time.Duration(consensus.roundsCompleted-1)
+	}
+	return stats
+}
+
+// recordRoundFinished updates the round statistics once a block is committed,
+// caller's responsibility to hold the consensus mutex.
+func (consensus *Consensus) recordRoundFinished() {
+	now := time.Now()
+	if !consensus.lastRoundFinish.IsZero() {
+		consensus.totalRoundDuration += now.Sub(consensus.lastRoundFinish)
+	}
+	consensus.roundsCompleted++
+	consensus.lastRoundFinish = now
+}
+
+// recordResponse records the latency of a validator answering this round's
+// announce message.  Nothing is recorded if this node didn't announce the
+// block, e.g. it became leader through a view change in the middle of a round.
+func (consensus *Consensus) recordResponse(validatorPubKey string, now time.Time) {
+	if consensus.announceTime.IsZero() {
+		return
+	}
+	consensus.recordLatency(validatorPubKey, now.Sub(consensus.announceTime))
+}
+
+// recordLatency records how long the validator took to answer the announce
+// message, caller's responsibility to hold the consensus mutex.
+func (consensus *Consensus) recordLatency(validatorPubKey string, latency time.Duration) {
+	if consensus.roundLatencies == nil {
+		consensus.roundLatencies = map[string]time.Duration{}
+	}
+	if consensus.avgLatencies == nil {
+		consensus.avgLatencies = map[string]time.Duration{}
+	}
+	consensus.roundLatencies[validatorPubKey] = latency
+	if avg, ok := consensus.avgLatencies[validatorPubKey]; ok {
+		latency = time.Duration(latencyEMAWeight*float64(latency) + (1-latencyEMAWeight)*float64(avg))
+	}
+	consensus.avgLatencies[validatorPubKey] = latency
+}
+
+// EstimatedRoundDuration returns a conservative estimate of how long the
+// current round still takes: the 95th percentile of the average latencies of
+// the validators the leader is still waiting for, so it shrinks as responses
+// come in and is 0 once nobody is pending.  When none of the pending
+// validators has answered before, the latencies of all validators are used.
+// It returns 0 when no latency has been recorded yet, e.g. on validators,
+// which don't collect them.
+func (consensus *Consensus) EstimatedRoundDuration() time.Duration {
+	consensus.mutex.Lock()
+	defer consensus.mutex.Unlock()
+
+	if len(consensus.avgLatencies) == 0 {
+		return 0
+	}
+	latencies := []time.Duration{}
+	if consensus.IsLeader() {
+		pending := consensus.pendingResponders()
+		if len(pending) == 0 {
+			return 0
+		}
+		for _, key := range pending {
+			if latency, ok := consensus.avgLatencies[key.SerializeToHexStr()]; ok {
+				latencies = append(latencies, latency)
+			}
+		}
+	}
+	if len(latencies) == 0 {
+		for _, latency := range consensus.avgLatencies {
+			latencies = append(latencies, latency)
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	rank := int(math.Ceil(roundEstimatePercentile*float64(len(latencies)))) - 1
+	return latencies[rank]
+}
+
+// PendingResponders returns the validators the leader is still waiting for in
+// the current round: those without a prepare signature until the prepared
+// message is sent, then those without a commit signature.  It returns nil on
+// validators, which don't collect signatures.
+func (consensus *Consensus) PendingResponders() []*bls.PublicKey {
+	consensus.mutex.Lock()
+	defer consensus.mutex.Unlock()
+
+	if !consensus.IsLeader() {
+		return nil
+	}
+	return consensus.pendingResponders()
+}
+
+// pendingResponders returns the validators without a signature in the bitmap
+// of the current phase, caller's responsibility to hold the consensus mutex.
+func (consensus *Consensus) pendingResponders() []*bls.PublicKey {
+	mask := consensus.prepareBitmap
+	if consensus.phase == Commit {
+		mask = consensus.commitBitmap
+	}
+	if mask == nil {
+		return nil
+	}
+	return mask.GetPubKeyFromMask(false)
+}
+
+// RoundsCommitted returns how many blocks this node has committed.
+func (consensus *Consensus) RoundsCommitted() uint64 {
+	return atomic.LoadUint64(&consensus.roundsCommitted)
+}
+
+// RoundsTimedOut returns how many times a consensus phase timed out.
+func (consensus *Consensus) RoundsTimedOut() uint64 {
+	return atomic.LoadUint64(&consensus.roundsTimedOut)
+}
+
+// RoundsViewChanged returns how many view changes this node completed.
+func (consensus *Consensus) RoundsViewChanged() uint64 {
+	return atomic.LoadUint64(&consensus.roundsViewChanged)
+}
+
+// MessagesRejected returns how many received messages were dropped as
+// unparseable, from another shard or from a sender outside the committee.
+func (consensus *Consensus) MessagesRejected() uint64 {
+	return atomic.LoadUint64(&consensus.messagesRejected)
+}
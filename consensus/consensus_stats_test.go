@@ -0,0 +1,144 @@
+package consensus
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	ffi_bls "github.com/harmony-one/bls/ffi/go/bls"
+)
+
+func TestStats(t *testing.T) {
+	consensus := &Consensus{ShardID: 1, viewID: 5, blockNum: 3}
+	stats := consensus.Stats()
+	if stats.ShardID != 1 || stats.ViewID != 5 || stats.BlockNum != 3 {
+		t.Errorf("Stats does not reflect the consensus state: %+v", stats)
+	}
+	if stats.RoundsCompleted != 0 || stats.AverageRoundDuration != 0 {
+		t.Errorf("No round should be reported yet: %+v", stats)
+	}
+
+	consensus.recordRoundFinished()
+	consensus.lastRoundFinish = consensus.lastRoundFinish.Add(-2 * time.Second)
+	consensus.recordRoundFinished()
+	stats = consensus.Stats()
+	if stats.RoundsCompleted != 2 {
+		t.Errorf("Expected 2 completed rounds, got %d", stats.RoundsCompleted)
+	}
+	if stats.AverageRoundDuration < 2*time.Second {
+		t.Errorf("Average round duration is too short: %v", stats.AverageRoundDuration)
+	}
+}
+
+func TestRecordLatency(t *testing.T) {
+	consensus := &Consensus{}
+	consensus.recordLatency("validator", 10*time.Millisecond)
+	consensus.recordLatency("validator", 20*time.Millisecond)
+	stats := consensus.Stats()
+	if stats.RoundLatencies["validator"] != 20*time.Millisecond {
+		t.Errorf("Expected the latest latency of this round, got %v", stats.RoundLatencies["validator"])
+	}
+	if stats.AverageLatencies["validator"] != 12*time.Millisecond {
+		t.Errorf("Expected the moving average 12ms, got %v", stats.AverageLatencies["validator"])
+	}
+}
+
+func TestRecordResponse(t *testing.T) {
+	consensus := &Consensus{}
+	now := time.Now()
+	consensus.recordResponse("validator", now)
+	if _, ok := consensus.Stats().AverageLatencies["validator"]; ok {
+		t.Error("No latency should be recorded without an announce time")
+	}
+
+	consensus.announceTime = now.Add(-10 * time.Millisecond)
+	consensus.recordResponse("validator", now)
+	if latency := consensus.Stats().AverageLatencies["validator"]; latency != 10*time.Millisecond {
+		t.Errorf("Expected a latency of 10ms since the announce, got %v", latency)
+	}
+}
+
+func TestEstimatedRoundDuration(t *testing.T) {
+	consensus := &Consensus{}
+	if d := consensus.EstimatedRoundDuration(); d != 0 {
+		t.Errorf("Expected no estimate without latencies, got %v", d)
+	}
+
+	for i := 1; i <= 20; i++ {
+		consensus.recordLatency(fmt.Sprintf("validator%d", i), time.Duration(i)*time.Millisecond)
+	}
+	if d := consensus.EstimatedRoundDuration(); d != 19*time.Millisecond {
+		t.Errorf("Expected the 95th percentile 19ms, got %v", d)
+	}
+
+	consensus.recordLatency("validator21", time.Second)
+	if d := consensus.EstimatedRoundDuration(); d != 20*time.Millisecond {
+		t.Errorf("Expected a single outlier not to dominate the estimate, got %v", d)
+	}
+}
+
+func TestEstimatedRoundDurationPending(t *testing.T) {
+	consensus := &Consensus{}
+	_, peers, _ := NewTestCommittee(4)
+	keys := []*ffi_bls.PublicKey{}
+	for _, peer := range peers {
+		keys = append(keys, peer.ConsensusPubKey)
+	}
+	consensus.UpdatePublicKeys(keys)
+	consensus.PubKey = keys[0]
+	for i := 1; i < len(keys); i++ {
+		consensus.recordLatency(keys[i].SerializeToHexStr(), time.Duration(i)*10*time.Millisecond)
+	}
+
+	consensus.switchPhase(Prepare, true)
+	consensus.prepareBitmap.SetKey(keys[0], true)
+	if d := consensus.EstimatedRoundDuration(); d != 30*time.Millisecond {
+		t.Errorf("Expected the slowest pending validator 30ms, got %v", d)
+	}
+	consensus.prepareBitmap.SetKey(keys[3], true)
+	if d := consensus.EstimatedRoundDuration(); d != 20*time.Millisecond {
+		t.Errorf("Expected the estimate to drop to 20ms once the slowest validator answered, got %v", d)
+	}
+	consensus.prepareBitmap.SetKey(keys[1], true)
+	consensus.prepareBitmap.SetKey(keys[2], true)
+	if d := consensus.EstimatedRoundDuration(); d != 0 {
+		t.Errorf("Expected no remaining time once nobody is pending, got %v", d)
+	}
+}
+
+func TestPendingResponders(t *testing.T) {
+	consensus := &Consensus{}
+	_, peers, _ := NewTestCommittee(4)
+	keys := []*ffi_bls.PublicKey{}
+	for _, peer := range peers {
+		keys = append(keys, peer.ConsensusPubKey)
+	}
+	consensus.UpdatePublicKeys(keys)
+
+	consensus.PubKey = keys[1]
+	if pending := consensus.PendingResponders(); pending != nil {
+		t.Errorf("A validator should not report pending responders, got %d", len(pending))
+	}
+
+	consensus.PubKey = keys[0]
+	consensus.switchPhase(Prepare, true)
+	consensus.prepareBitmap.SetKey(keys[0], true)
+	consensus.prepareBitmap.SetKey(keys[1], true)
+	assertPending(t, consensus.PendingResponders(), keys[2], keys[3])
+
+	consensus.switchPhase(Commit, true)
+	consensus.commitBitmap.SetKey(keys[0], true)
+	assertPending(t, consensus.PendingResponders(), keys[1], keys[2], keys[3])
+}
+
+func assertPending(t *testing.T, pending []*ffi_bls.PublicKey, expected ...*ffi_bls.PublicKey) {
+	t.Helper()
+	if len(pending) != len(expected) {
+		t.Fatalf("Expected %d pending validators, got %d", len(expected), len(pending))
+	}
+	for i := range expected {
+		if !pending[i].IsEqual(expected[i]) {
+			t.Errorf("Pending validator %d is %s, expected %s", i, pending[i].SerializeToHexStr(), expected[i].SerializeToHexStr())
+		}
+	}
+}
@@ -1,8 +1,13 @@
 package consensus
 
 import (
+	"runtime"
 	"testing"
+	"time"
 
+	ffi_bls "github.com/harmony-one/bls/ffi/go/bls"
+
+	"github.com/harmony-one/harmony/core/types"
 	"github.com/harmony-one/harmony/crypto/bls"
 	"github.com/harmony-one/harmony/internal/utils"
 	"github.com/harmony-one/harmony/p2p"
@@ -28,3 +33,180 @@ func TestNew(test *testing.T) {
 		test.Error("Consensus ReadySignal should be initialized")
 	}
 }
+
+func TestHasQuorum(test *testing.T) {
+	consensus := &Consensus{}
+	tests := []struct {
+		numKeys int
+		quorum  int
+	}{
+		{1, 1},
+		{2, 2},
+		{3, 3},
+		{4, 3},
+		{10, 7},
+		{100, 67},
+	}
+	for _, tt := range tests {
+		consensus.PublicKeys = make([]*ffi_bls.PublicKey, tt.numKeys)
+		if consensus.HasQuorum(tt.quorum - 1) {
+			test.Errorf("%d of %d signatures should not reach quorum", tt.quorum-1, tt.numKeys)
+		}
+		if !consensus.HasQuorum(tt.quorum) {
+			test.Errorf("%d of %d signatures should reach quorum", tt.quorum, tt.numKeys)
+		}
+	}
+}
+
+func TestSetConsensusTimeout(test *testing.T) {
+	leader := p2p.Peer{IP: "127.0.0.1", Port: "9902"}
+	priKey, _, _ := utils.GenKeyP2P("127.0.0.1", "9902")
+	host, err := p2pimpl.NewHost(&leader, priKey)
+	if err != nil {
+		test.Fatalf("newhost failure: %v", err)
+	}
+	consensus, err := New(host, 0, leader, bls.RandPrivateKey())
+	if err != nil {
+		test.Fatalf("Cannot craeate consensus: %v", err)
+	}
+
+	if d := consensus.consensusTimeout[timeoutConsensus].Duration(); d != phaseDuration {
+		test.Errorf("Consensus timeout is initialized to the wrong value: %v", d)
+	}
+	consensus.SetConsensusTimeout(time.Second)
+	if d := consensus.consensusTimeout[timeoutConsensus].Duration(); d != time.Second {
+		test.Errorf("Consensus timeout not updated. Got: %v, Expected: %v", d, time.Second)
+	}
+}
+
+func TestClose(test *testing.T) {
+	leader := p2p.Peer{IP: "127.0.0.1", Port: "9902"}
+	priKey, _, _ := utils.GenKeyP2P("127.0.0.1", "9902")
+	host, err := p2pimpl.NewHost(&leader, priKey)
+	if err != nil {
+		test.Fatalf("newhost failure: %v", err)
+	}
+	consensus, err := New(host, 0, leader, bls.RandPrivateKey())
+	if err != nil {
+		test.Fatalf("Cannot craeate consensus: %v", err)
+	}
+
+	stoppedChan := make(chan struct{})
+	consensus.Start(make(chan *types.Block), make(chan struct{}), stoppedChan, make(chan struct{}))
+	consensus.Close()
+	consensus.Close()
+	select {
+	case <-stoppedChan:
+	case <-time.After(5 * time.Second):
+		test.Error("Consensus main loop did not stop after Close")
+	}
+
+	if !consensus.IsClosed() {
+		test.Error("Consensus should report being closed")
+	}
+
+	pushed := make(chan bool)
+	go func() { pushed <- consensus.PushMessage([]byte{}) }()
+	select {
+	case ok := <-pushed:
+		if ok {
+			test.Error("Message pushed after Close should be dropped")
+		}
+	case <-time.After(time.Second):
+		test.Error("PushMessage blocked after Close")
+	}
+}
+
+func TestNewTestCommittee(test *testing.T) {
+	leader, peers, priKeys := NewTestCommittee(4)
+	_, samePeers, _ := NewTestCommittee(4)
+	if !leader.ConsensusPubKey.IsEqual(peers[0].ConsensusPubKey) {
+		test.Error("The leader should be the first peer")
+	}
+	for i, peer := range peers {
+		if !peer.ConsensusPubKey.IsEqual(samePeers[i].ConsensusPubKey) {
+			test.Errorf("Key of peer %d is not deterministic", i)
+		}
+		if !priKeys[i].Sign("test").Verify(peer.ConsensusPubKey, "test") {
+			test.Errorf("Signature of peer %d does not verify", i)
+		}
+	}
+}
+
+func TestHaltAfterConsecutiveFailures(test *testing.T) {
+	consensus := &Consensus{}
+	consensus.recordRoundFailure()
+	consensus.recordRoundFailure()
+	if consensus.IsHalted() {
+		test.Error("Consensus should never halt by default")
+	}
+
+	consensus.Resume()
+	consensus.SetMaxConsecutiveFailures(2)
+	consensus.recordRoundFailure()
+	consensus.recordRoundSuccess()
+	consensus.recordRoundFailure()
+	if consensus.IsHalted() {
+		test.Error("A committed block should reset the failure count")
+	}
+	consensus.recordRoundFailure()
+	if !consensus.IsHalted() {
+		test.Error("Consensus should halt after 2 consecutive failures")
+	}
+
+	consensus.Resume()
+	if consensus.IsHalted() {
+		test.Error("Consensus should not be halted after Resume")
+	}
+}
+
+func TestResumeSignalsLeader(test *testing.T) {
+	_, peers, _ := NewTestCommittee(2)
+	consensus := &Consensus{ReadySignal: make(chan struct{}, 1)}
+	consensus.PubKey = peers[0].ConsensusPubKey
+	consensus.setLeaderPubKey(peers[1].ConsensusPubKey)
+	consensus.SetMaxConsecutiveFailures(1)
+
+	consensus.recordRoundFailure()
+	consensus.Resume()
+	if len(consensus.ReadySignal) != 0 {
+		test.Error("A resumed validator should not be signalled to propose")
+	}
+
+	consensus.setLeaderPubKey(peers[0].ConsensusPubKey)
+	consensus.recordRoundFailure()
+	consensus.Resume()
+	select {
+	case <-consensus.ReadySignal:
+	default:
+		test.Fatal("A resumed leader should be signalled to propose a new block")
+	}
+
+	consensus.Resume()
+	if len(consensus.ReadySignal) != 0 {
+		test.Error("Resuming a node that is not halted should not trigger a proposal")
+	}
+}
+
+func TestSignalReady(test *testing.T) {
+	consensus := &Consensus{ReadySignal: make(chan struct{}, 1)}
+	goroutines := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		// nobody reads, must neither block nor spawn goroutines
+		consensus.signalReady()
+	}
+	if n := runtime.NumGoroutine(); n > goroutines {
+		test.Errorf("signalReady leaked %d goroutines", n-goroutines)
+	}
+	if len(consensus.ReadySignal) != 1 {
+		test.Errorf("Expected one coalesced ready signal, got %d", len(consensus.ReadySignal))
+	}
+
+	<-consensus.ReadySignal
+	consensus.signalReady()
+	select {
+	case <-consensus.ReadySignal:
+	default:
+		test.Error("A new ready signal should be sent after the pending one was read")
+	}
+}
@@ -2,8 +2,8 @@ package consensus
 
 import (
 	"bytes"
-	"encoding/binary"
 	"encoding/hex"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -32,6 +32,7 @@ func (consensus *Consensus) handleMessageUpdate(payload []byte) {
 	err := protobuf.Unmarshal(payload, msg)
 	if err != nil {
 		utils.Logger().Error().Err(err).Interface("consensus", consensus).Msg("Failed to unmarshal message payload.")
+		atomic.AddUint64(&consensus.messagesRejected, 1)
 		return
 	}
 
@@ -52,6 +53,7 @@ func (consensus *Consensus) handleMessageUpdate(payload []byte) {
 				Uint32("myShardId", consensus.ShardID).
 				Uint32("receivedShardId", msg.GetViewchange().ShardId).
 				Msg("Received view change message from different shard")
+			atomic.AddUint64(&consensus.messagesRejected, 1)
 			return
 		}
 	} else {
@@ -60,6 +62,7 @@ func (consensus *Consensus) handleMessageUpdate(payload []byte) {
 				Uint32("myShardId", consensus.ShardID).
 				Uint32("receivedShardId", msg.GetConsensus().ShardId).
 				Msg("Received consensus message from different shard")
+			atomic.AddUint64(&consensus.messagesRejected, 1)
 			return
 		}
 	}
@@ -120,6 +123,7 @@ func (consensus *Consensus) announce(block *types.Block) {
 		Uint64("MsgBlockNum", pbftMsg.BlockNum).
 		Msg("[Announce] Added Announce message in pbftLog")
 	consensus.PbftLog.AddBlock(block)
+	consensus.announceTime = time.Now()
 
 	// Leader sign the block hash itself
 	consensus.prepareSigs[consensus.PubKey.SerializeToHexStr()] = consensus.priKey.SignHash(consensus.blockHash[:])
@@ -146,6 +150,13 @@ func (consensus *Consensus) announce(block *types.Block) {
 		Str("To", Prepare.String()).
 		Msg("[Announce] Switching phase")
 	consensus.switchPhase(Prepare, true)
+
+	// a committee too small to need any other prepare is prepared already
+	consensus.mutex.Lock()
+	defer consensus.mutex.Unlock()
+	if consensus.HasQuorum(len(consensus.prepareSigs)) {
+		consensus.sendPreparedMessage()
+	}
 }
 
 func (consensus *Consensus) onAnnounce(msg *msg_pb.Message) {
@@ -159,10 +170,10 @@ func (consensus *Consensus) onAnnounce(msg *msg_pb.Message) {
 		consensus.getLogger().Error().Err(err).Msg("[OnAnnounce] VerifySenderKey failed")
 		return
 	}
-	if !senderKey.IsEqual(consensus.LeaderPubKey) && consensus.mode.Mode() == Normal && !consensus.ignoreViewIDCheck {
+	if !senderKey.IsEqual(consensus.GetLeaderPubKey()) && consensus.mode.Mode() == Normal && !consensus.ignoreViewIDCheck {
 		consensus.getLogger().Warn().
 			Str("senderKey", senderKey.SerializeToHexStr()).
-			Str("leaderKey", consensus.LeaderPubKey.SerializeToHexStr()).
+			Str("leaderKey", consensus.GetLeaderPubKey().SerializeToHexStr()).
 			Msg("[OnAnnounce] SenderKey does not match leader PubKey")
 		return
 	}
@@ -192,7 +203,14 @@ func (consensus *Consensus) onAnnounce(msg *msg_pb.Message) {
 		return
 	}
 
-	if recvMsg.BlockNum < consensus.blockNum || recvMsg.BlockNum != headerObj.Number.Uint64() {
+	if recvMsg.BlockNum < consensus.blockNum {
+		consensus.getLogger().Debug().
+			Uint64("MsgBlockNum", recvMsg.BlockNum).
+			Uint64("blockNum", consensus.blockNum).
+			Msg("[OnAnnounce] Old block received, ignoring")
+		return
+	}
+	if recvMsg.BlockNum != headerObj.Number.Uint64() {
 		consensus.getLogger().Debug().
 			Uint64("MsgBlockNum", recvMsg.BlockNum).
 			Uint64("blockNum", consensus.blockNum).
@@ -200,6 +218,14 @@ func (consensus *Consensus) onAnnounce(msg *msg_pb.Message) {
 			Msg("[OnAnnounce] BlockNum does not match")
 		return
 	}
+	if headerObj.Hash() != recvMsg.BlockHash {
+		consensus.getLogger().Warn().
+			Uint64("MsgBlockNum", recvMsg.BlockNum).
+			Str("MsgBlockHash", recvMsg.BlockHash.Hex()).
+			Str("hdrBlockHash", headerObj.Hash().Hex()).
+			Msg("[OnAnnounce] BlockHash does not match the block header")
+		return
+	}
 	if consensus.mode.Mode() == Normal {
 		if err = chain.Engine.VerifyHeader(consensus.ChainReader, &headerObj, true); err != nil {
 			consensus.getLogger().Warn().
@@ -209,6 +235,22 @@ func (consensus *Consensus) onAnnounce(msg *msg_pb.Message) {
 				Msg("[OnAnnounce] Block content is not verified successfully")
 			return
 		}
+		if consensus.HeaderVerifier != nil {
+			if err := consensus.HeaderVerifier(&headerObj); err != nil {
+				consensus.getLogger().Warn().
+					Err(err).
+					Str("MsgBlockNum", headerObj.Number.String()).
+					Msg("[OnAnnounce] Block header verification failed")
+				return
+			}
+		}
+		if err := consensus.verifyBlockTime(&headerObj, time.Now()); err != nil {
+			consensus.getLogger().Warn().
+				Err(err).
+				Str("MsgBlockNum", headerObj.Number.String()).
+				Msg("[OnAnnounce] Block timestamp rejected")
+			return
+		}
 
 		//VRF/VDF is only generated in the beach chain
 		if consensus.NeedsRandomNumberGeneration(headerObj.Epoch) {
@@ -231,13 +273,17 @@ func (consensus *Consensus) onAnnounce(msg *msg_pb.Message) {
 	logMsgs := consensus.PbftLog.GetMessagesByTypeSeqView(msg_pb.MessageType_ANNOUNCE, recvMsg.BlockNum, recvMsg.ViewID)
 	if len(logMsgs) > 0 {
 		if logMsgs[0].BlockHash != recvMsg.BlockHash {
-			consensus.getLogger().Debug().
-				Str("leaderKey", consensus.LeaderPubKey.SerializeToHexStr()).
-				Msg("[OnAnnounce] Leader is malicious")
+			consensus.getLogger().Warn().
+				Str("leaderKey", consensus.GetLeaderPubKey().SerializeToHexStr()).
+				Str("loggedBlockHash", logMsgs[0].BlockHash.Hex()).
+				Str("MsgBlockHash", recvMsg.BlockHash.Hex()).
+				Uint64("MsgBlockNum", recvMsg.BlockNum).
+				Uint64("MsgViewID", recvMsg.ViewID).
+				Msg("[OnAnnounce] Leader is malicious, two different blocks announced in the same view")
 			consensus.startViewChange(consensus.viewID + 1)
 		}
 		consensus.getLogger().Debug().
-			Str("leaderKey", consensus.LeaderPubKey.SerializeToHexStr()).
+			Str("leaderKey", consensus.GetLeaderPubKey().SerializeToHexStr()).
 			Msg("[OnAnnounce] Announce message received again")
 		//return
 	}
@@ -342,14 +388,14 @@ func (consensus *Consensus) onPrepare(msg *msg_pb.Message) {
 	consensus.mutex.Lock()
 	defer consensus.mutex.Unlock()
 	logger := consensus.getLogger().With().Str("validatorPubKey", validatorPubKey).Logger()
-	if len(prepareSigs) >= consensus.Quorum() {
+	if consensus.HasQuorum(len(prepareSigs)) {
 		// already have enough signatures
 		logger.Debug().Msg("[OnPrepare] Received Additional Prepare Message")
 		return
 	}
 	// proceed only when the message is not received before
-	_, ok := prepareSigs[validatorPubKey]
-	if ok {
+	existingSig, received := prepareSigs[validatorPubKey]
+	if received && bytes.Equal(existingSig.Serialize(), prepareSig) {
 		logger.Debug().Msg("[OnPrepare] Already Received prepare message from the validator")
 		return
 	}
@@ -365,61 +411,87 @@ func (consensus *Consensus) onPrepare(msg *msg_pb.Message) {
 		consensus.getLogger().Error().Msg("[OnPrepare] Received invalid BLS signature")
 		return
 	}
+	if received {
+		// BLS signatures are deterministic, an honest validator cannot produce two different valid ones
+		logger.Warn().Msg("[OnPrepare] Received conflicting prepare message from the validator, possible equivocation")
+		return
+	}
 
 	logger = logger.With().Int("NumReceivedSoFar", len(prepareSigs)).Int("PublicKeys", len(consensus.PublicKeys)).Logger()
 	logger.Info().Msg("[OnPrepare] Received New Prepare Signature")
 	prepareSigs[validatorPubKey] = &sign
+	consensus.recordResponse(validatorPubKey, time.Now())
 	// Set the bitmap indicating that this validator signed.
 	if err := prepareBitmap.SetKey(recvMsg.SenderPubkey, true); err != nil {
 		consensus.getLogger().Warn().Err(err).Msg("[OnPrepare] prepareBitmap.SetKey failed")
 		return
 	}
 
-	if len(prepareSigs) >= consensus.Quorum() {
+	if consensus.HasQuorum(len(prepareSigs)) {
 		logger.Debug().Msg("[OnPrepare] Received Enough Prepare Signatures")
-		// Construct and broadcast prepared message
-		msgToSend, aggSig := consensus.constructPreparedMessage()
-		consensus.aggregatedPrepareSig = aggSig
-
-		//leader adds prepared message to log
-		msgPayload, _ := proto.GetConsensusMessagePayload(msgToSend)
-		msg := &msg_pb.Message{}
-		_ = protobuf.Unmarshal(msgPayload, msg)
-		pbftMsg, err := ParsePbftMessage(msg)
-		if err != nil {
-			consensus.getLogger().Warn().Err(err).Msg("[OnPrepare] Unable to parse pbft message")
-			return
-		}
-		consensus.PbftLog.AddMessage(pbftMsg)
-
-		// Leader add commit phase signature
-		blockNumHash := make([]byte, 8)
-		binary.LittleEndian.PutUint64(blockNumHash, consensus.blockNum)
-		commitPayload := append(blockNumHash, consensus.blockHash[:]...)
-		consensus.commitSigs[consensus.PubKey.SerializeToHexStr()] = consensus.priKey.SignHash(commitPayload)
-		if err := consensus.commitBitmap.SetKey(consensus.PubKey, true); err != nil {
-			consensus.getLogger().Debug().Msg("[OnPrepare] Leader commit bitmap set failed")
-			return
-		}
+		consensus.sendPreparedMessage()
+	}
+	return
+}
 
-		if err := consensus.msgSender.SendWithRetry(consensus.blockNum, msg_pb.MessageType_PREPARED, []p2p.GroupID{p2p.NewGroupIDByShardID(p2p.ShardID(consensus.ShardID))}, host.ConstructP2pMessage(byte(17), msgToSend)); err != nil {
-			consensus.getLogger().Warn().Msg("[OnPrepare] Cannot send prepared message")
-		} else {
-			consensus.getLogger().Debug().
-				Bytes("blockHash", consensus.blockHash[:]).
-				Uint64("blockNum", consensus.blockNum).
-				Msg("[OnPrepare] Sent Prepared Message!!")
-		}
-		consensus.msgSender.StopRetry(msg_pb.MessageType_ANNOUNCE)
-		consensus.msgSender.StopRetry(msg_pb.MessageType_COMMITTED) // Stop retry committed msg of last consensus
+// sendPreparedMessage is called by the leader, holding the mutex, once it has
+// a quorum of prepare signatures: it broadcasts the prepared message, adds its
+// own commit signature and switches to the commit phase.
+func (consensus *Consensus) sendPreparedMessage() {
+	if err := checkBitmapMatchesSigs(consensus.prepareSigs, consensus.prepareBitmap); err != nil {
+		consensus.getLogger().Error().Err(err).Msg("[OnPrepare] Not sending prepared message")
+		return
+	}
+
+	// Construct and broadcast prepared message
+	msgToSend, aggSig := consensus.constructPreparedMessage()
+	consensus.aggregatedPrepareSig = aggSig
+
+	//leader adds prepared message to log
+	msgPayload, _ := proto.GetConsensusMessagePayload(msgToSend)
+	msg := &msg_pb.Message{}
+	_ = protobuf.Unmarshal(msgPayload, msg)
+	pbftMsg, err := ParsePbftMessage(msg)
+	if err != nil {
+		consensus.getLogger().Warn().Err(err).Msg("[OnPrepare] Unable to parse pbft message")
+		return
+	}
+	consensus.PbftLog.AddMessage(pbftMsg)
 
+	// Leader add commit phase signature
+	commitPayload := ConstructCommitPayload(consensus.blockNum, consensus.blockHash)
+	consensus.commitSigs[consensus.PubKey.SerializeToHexStr()] = consensus.priKey.SignHash(commitPayload)
+	if err := consensus.commitBitmap.SetKey(consensus.PubKey, true); err != nil {
+		consensus.getLogger().Debug().Msg("[OnPrepare] Leader commit bitmap set failed")
+		return
+	}
+
+	if err := consensus.msgSender.SendWithRetry(consensus.blockNum, msg_pb.MessageType_PREPARED, []p2p.GroupID{p2p.NewGroupIDByShardID(p2p.ShardID(consensus.ShardID))}, host.ConstructP2pMessage(byte(17), msgToSend)); err != nil {
+		consensus.getLogger().Warn().Msg("[OnPrepare] Cannot send prepared message")
+	} else {
 		consensus.getLogger().Debug().
-			Str("From", consensus.phase.String()).
-			Str("To", Commit.String()).
-			Msg("[OnPrepare] Switching phase")
-		consensus.switchPhase(Commit, true)
+			Bytes("blockHash", consensus.blockHash[:]).
+			Uint64("blockNum", consensus.blockNum).
+			Msg("[OnPrepare] Sent Prepared Message!!")
+	}
+	consensus.msgSender.StopRetry(msg_pb.MessageType_ANNOUNCE)
+	consensus.msgSender.StopRetry(msg_pb.MessageType_COMMITTED) // Stop retry committed msg of last consensus
+
+	consensus.getLogger().Debug().
+		Str("From", consensus.phase.String()).
+		Str("To", Commit.String()).
+		Msg("[OnPrepare] Switching phase")
+	consensus.switchPhase(Commit, true)
+
+	// a committee too small to need any other commit is done already
+	if consensus.HasQuorum(len(consensus.commitSigs)) {
+		go func(viewID uint64) {
+			select {
+			case consensus.commitFinishChan <- viewID:
+			case <-consensus.done:
+			}
+		}(consensus.viewID)
 	}
-	return
 }
 
 func (consensus *Consensus) onPrepared(msg *msg_pb.Message) {
@@ -433,7 +505,7 @@ func (consensus *Consensus) onPrepared(msg *msg_pb.Message) {
 		consensus.getLogger().Debug().Err(err).Msg("[OnPrepared] VerifySenderKey failed")
 		return
 	}
-	if !senderKey.IsEqual(consensus.LeaderPubKey) && consensus.mode.Mode() == Normal && !consensus.ignoreViewIDCheck {
+	if !senderKey.IsEqual(consensus.GetLeaderPubKey()) && consensus.mode.Mode() == Normal && !consensus.ignoreViewIDCheck {
 		consensus.getLogger().Warn().Msg("[OnPrepared] SenderKey not match leader PubKey")
 		return
 	}
@@ -464,7 +536,7 @@ func (consensus *Consensus) onPrepared(msg *msg_pb.Message) {
 		consensus.getLogger().Error().Err(err).Msg("ReadSignatureBitmapPayload failed!!")
 		return
 	}
-	if count := utils.CountOneBits(mask.Bitmap); count < consensus.Quorum() {
+	if count := utils.CountOneBits(mask.Bitmap); !consensus.HasQuorum(count) {
 		consensus.getLogger().Debug().
 			Int("Need", consensus.Quorum()).
 			Int("Got", count).
@@ -507,6 +579,15 @@ func (consensus *Consensus) onPrepared(msg *msg_pb.Message) {
 			Msg("[OnPrepared] BlockHash not match")
 		return
 	}
+	if err := consensus.verifyBlockLimits(block, &blockObj); err != nil {
+		violations := consensus.recordBlockLimitViolation(senderKey)
+		consensus.getLogger().Warn().
+			Err(err).
+			Uint64("MsgBlockNum", recvMsg.BlockNum).
+			Uint64("leaderViolations", violations).
+			Msg("[OnPrepared] Block violates the configured limits")
+		return
+	}
 	if consensus.mode.Mode() == Normal {
 		if err := chain.Engine.VerifyHeader(consensus.ChainReader, blockObj.Header(), true); err != nil {
 			consensus.getLogger().Warn().
@@ -576,9 +657,7 @@ func (consensus *Consensus) onPrepared(msg *msg_pb.Message) {
 
 	// Construct and send the commit message
 	// TODO: should only sign on block hash
-	blockNumBytes := make([]byte, 8)
-	binary.LittleEndian.PutUint64(blockNumBytes, consensus.blockNum)
-	commitPayload := append(blockNumBytes, consensus.blockHash[:]...)
+	commitPayload := ConstructCommitPayload(consensus.blockNum, consensus.blockHash)
 	msgToSend := consensus.constructCommitMessage(commitPayload)
 
 	// TODO: genesis account node delay for 1 second, this is a temp fix for allows FN nodes to earning reward
@@ -670,13 +749,13 @@ func (consensus *Consensus) onCommit(msg *msg_pb.Message) {
 	commitBitmap := consensus.commitBitmap
 
 	// proceed only when the message is not received before
-	_, ok := commitSigs[validatorPubKey]
-	if ok {
+	existingSig, received := commitSigs[validatorPubKey]
+	if received && bytes.Equal(existingSig.Serialize(), commitSig) {
 		logger.Debug().Msg("[OnCommit] Already received commit message from the validator")
 		return
 	}
 
-	quorumWasMet := len(commitSigs) >= consensus.Quorum()
+	quorumWasMet := consensus.HasQuorum(len(commitSigs))
 
 	// Verify the signature on commitPayload is correct
 	var sign bls.Sign
@@ -685,14 +764,17 @@ func (consensus *Consensus) onCommit(msg *msg_pb.Message) {
 		logger.Debug().Msg("[OnCommit] Failed to deserialize bls signature")
 		return
 	}
-	blockNumHash := make([]byte, 8)
-	binary.LittleEndian.PutUint64(blockNumHash, recvMsg.BlockNum)
-	commitPayload := append(blockNumHash, recvMsg.BlockHash[:]...)
+	commitPayload := ConstructCommitPayload(recvMsg.BlockNum, recvMsg.BlockHash)
 	logger = logger.With().Uint64("MsgViewID", recvMsg.ViewID).Uint64("MsgBlockNum", recvMsg.BlockNum).Logger()
 	if !sign.VerifyHash(recvMsg.SenderPubkey, commitPayload) {
 		logger.Error().Msg("[OnCommit] Cannot verify commit message")
 		return
 	}
+	if received {
+		// BLS signatures are deterministic, an honest validator cannot produce two different valid ones
+		logger.Warn().Msg("[OnCommit] Received conflicting commit message from the validator, possible equivocation")
+		return
+	}
 
 	logger = logger.With().Int("numReceivedSoFar", len(commitSigs)).Logger()
 	logger.Info().Msg("[OnCommit] Received new commit message")
@@ -703,23 +785,30 @@ func (consensus *Consensus) onCommit(msg *msg_pb.Message) {
 		return
 	}
 
-	quorumIsMet := len(commitSigs) >= consensus.Quorum()
+	quorumIsMet := consensus.HasQuorum(len(commitSigs))
 	rewardThresholdIsMet := len(commitSigs) >= consensus.RewardThreshold()
 
 	if !quorumWasMet && quorumIsMet {
 		logger.Info().Msg("[OnCommit] 2/3 Enough commits received")
-		go func(viewID uint64) {
-			time.Sleep(2 * time.Second)
+		go func(viewID uint64, gracePeriod time.Duration) {
+			time.Sleep(gracePeriod)
 			logger.Debug().Msg("[OnCommit] Commit Grace Period Ended")
-			consensus.commitFinishChan <- viewID
-		}(consensus.viewID)
+			select {
+			case consensus.commitFinishChan <- viewID:
+			case <-consensus.done:
+			}
+		}(consensus.viewID, consensus.commitGracePeriod)
 
 		consensus.msgSender.StopRetry(msg_pb.MessageType_PREPARED)
 	}
 
 	if rewardThresholdIsMet {
 		go func(viewID uint64) {
-			consensus.commitFinishChan <- viewID
+			select {
+			case consensus.commitFinishChan <- viewID:
+			case <-consensus.done:
+				return
+			}
 			logger.Info().Msg("[OnCommit] 90% Enough commits received")
 		}(consensus.viewID)
 	}
@@ -727,6 +816,10 @@ func (consensus *Consensus) onCommit(msg *msg_pb.Message) {
 
 func (consensus *Consensus) finalizeCommits() {
 	consensus.getLogger().Info().Int("NumCommits", len(consensus.commitSigs)).Msg("[Finalizing] Finalizing Block")
+	if err := checkBitmapMatchesSigs(consensus.commitSigs, consensus.commitBitmap); err != nil {
+		consensus.getLogger().Error().Err(err).Msg("[Finalizing] Not sending committed message")
+		return
+	}
 
 	beforeCatchupNum := consensus.blockNum
 	beforeCatchupViewID := consensus.viewID
@@ -744,8 +837,14 @@ func (consensus *Consensus) finalizeCommits() {
 		consensus.getLogger().Warn().Err(err).Msg("[FinalizeCommits] Unable to parse pbft message")
 		return
 	}
+	if _, err := consensus.verifyCommittedSig(pbftMsg); err != nil {
+		consensus.getLogger().Error().Err(err).Msg("[FinalizeCommits] Aggregated commit signature does not verify")
+		return
+	}
 	consensus.PbftLog.AddMessage(pbftMsg)
 	consensus.ChainReader.WriteLastCommits(pbftMsg.Payload)
+	// tryCatchup resets the round, so take the signatures before it runs
+	cert := consensus.newCommitCertificate()
 
 	// find correct block content
 	block := consensus.PbftLog.GetBlockByHash(consensus.blockHash)
@@ -762,6 +861,7 @@ func (consensus *Consensus) finalizeCommits() {
 			Msg("[FinalizeCommits] Leader cannot provide the correct block for committed message")
 		return
 	}
+	consensus.recordCommitCertificate(cert)
 	// if leader success finalize the block, send committed message to validators
 
 	if err := consensus.msgSender.SendWithRetry(block.NumberU64(), msg_pb.MessageType_COMMITTED, []p2p.GroupID{p2p.NewGroupIDByShardID(p2p.ShardID(consensus.ShardID))}, host.ConstructP2pMessage(byte(17), msgToSend)); err != nil {
@@ -797,7 +897,7 @@ func (consensus *Consensus) finalizeCommits() {
 		Msg("HOORAY!!!!!!! CONSENSUS REACHED!!!!!!!")
 
 	// Send signal to Node so the new block can be added and new round of consensus can be triggered
-	consensus.ReadySignal <- struct{}{}
+	consensus.signalReady()
 }
 
 func (consensus *Consensus) onCommitted(msg *msg_pb.Message) {
@@ -812,7 +912,7 @@ func (consensus *Consensus) onCommitted(msg *msg_pb.Message) {
 		consensus.getLogger().Warn().Err(err).Msg("[OnCommitted] verifySenderKey failed")
 		return
 	}
-	if !senderKey.IsEqual(consensus.LeaderPubKey) && consensus.mode.Mode() == Normal && !consensus.ignoreViewIDCheck {
+	if !senderKey.IsEqual(consensus.GetLeaderPubKey()) && consensus.mode.Mode() == Normal && !consensus.ignoreViewIDCheck {
 		consensus.getLogger().Warn().Msg("[OnCommitted] senderKey not match leader PubKey")
 		return
 	}
@@ -842,7 +942,7 @@ func (consensus *Consensus) onCommitted(msg *msg_pb.Message) {
 	}
 
 	// check has 2f+1 signatures
-	if count := utils.CountOneBits(mask.Bitmap); count < consensus.Quorum() {
+	if count := utils.CountOneBits(mask.Bitmap); !consensus.HasQuorum(count) {
 		consensus.getLogger().Warn().
 			Int("need", consensus.Quorum()).
 			Int("got", count).
@@ -850,9 +950,7 @@ func (consensus *Consensus) onCommitted(msg *msg_pb.Message) {
 		return
 	}
 
-	blockNumBytes := make([]byte, 8)
-	binary.LittleEndian.PutUint64(blockNumBytes, recvMsg.BlockNum)
-	commitPayload := append(blockNumBytes, recvMsg.BlockHash[:]...)
+	commitPayload := ConstructCommitPayload(recvMsg.BlockNum, recvMsg.BlockHash)
 	if !aggSig.VerifyHash(mask.AggregatePublic, commitPayload) {
 		consensus.getLogger().Error().
 			Uint64("MsgBlockNum", recvMsg.BlockNum).
@@ -977,13 +1075,30 @@ func (consensus *Consensus) tryCatchup() {
 		}
 		consensus.getLogger().Info().Msg("[TryCatchup] prepared message found to commit")
 
+		// the aggregated signature was verified when the message was received
+		_, signers, err := consensus.ReadSignatureBitmapPayload(msgs[0].Payload, 0)
+		if err != nil {
+			consensus.getLogger().Warn().Err(err).
+				Uint64("MsgBlockNum", msgs[0].BlockNum).
+				Msg("[TryCatchup] unable to read signers of committed message")
+			break
+		}
+
 		consensus.blockHash = [32]byte{}
 		consensus.blockNum = consensus.blockNum + 1
 		consensus.viewID = msgs[0].ViewID + 1
-		consensus.LeaderPubKey = msgs[0].SenderPubkey
+		consensus.setLeaderPubKey(msgs[0].SenderPubkey)
 
 		consensus.getLogger().Info().Msg("[TryCatchup] Adding block to chain")
 		consensus.OnConsensusDone(block)
+		consensus.setLastSigners(signers)
+		consensus.committedBlocks.add(msgs[0].ViewID, block)
+		if consensus.OnFinalized != nil {
+			consensus.OnFinalized(msgs[0].ViewID, block.NumberU64(), msgs[0].BlockHash)
+		}
+		consensus.recordRoundFinished()
+		consensus.recordRoundSuccess()
+		atomic.AddUint64(&consensus.roundsCommitted, 1)
 		consensus.ResetState()
 
 		select {
@@ -1019,14 +1134,17 @@ func (consensus *Consensus) Start(blockChannel chan *types.Block, stopChan chan
 	go func() {
 		if consensus.IsLeader() {
 			consensus.getLogger().Info().Time("time", time.Now()).Msg("[ConsensusMainLoop] Waiting for consensus start")
-			<-startChannel
+			select {
+			case <-startChannel:
+			case <-consensus.done:
+				close(stoppedChan)
+				return
+			}
 
 			// send a signal to indicate it's ready to run consensus
 			// this signal is consumed by node object to create a new block and in turn trigger a new consensus on it
-			go func() {
-				consensus.getLogger().Info().Time("time", time.Now()).Msg("[ConsensusMainLoop] Send ReadySignal")
-				consensus.ReadySignal <- struct{}{}
-			}()
+			consensus.getLogger().Info().Time("time", time.Now()).Msg("[ConsensusMainLoop] Send ReadySignal")
+			consensus.signalReady()
 		}
 		consensus.getLogger().Info().Time("time", time.Now()).Msg("[ConsensusMainLoop] Consensus started")
 		defer close(stoppedChan)
@@ -1050,6 +1168,7 @@ func (consensus *Consensus) Start(blockChannel chan *types.Block, stopChan chan
 					}
 					if k != timeoutViewChange {
 						consensus.getLogger().Debug().Msg("[ConsensusMainLoop] Ops Consensus Timeout!!!")
+						atomic.AddUint64(&consensus.roundsTimedOut, 1)
 						consensus.startViewChange(consensus.viewID + 1)
 						break
 					} else {
@@ -1072,6 +1191,12 @@ func (consensus *Consensus) Start(blockChannel chan *types.Block, stopChan chan
 				consensus.getLogger().Info().Msg("Node is out of sync")
 
 			case newBlock := <-blockChannel:
+				if consensus.IsHalted() {
+					consensus.getLogger().Warn().
+						Uint64("MsgBlockNum", newBlock.NumberU64()).
+						Msg("[ConsensusMainLoop] Halted, not proposing the new block")
+					continue
+				}
 				consensus.getLogger().Info().
 					Uint64("MsgBlockNum", newBlock.NumberU64()).
 					Msg("[ConsensusMainLoop] Received Proposed New Block!")
@@ -1177,6 +1302,9 @@ func (consensus *Consensus) Start(blockChannel chan *types.Block, stopChan chan
 
 			case <-stopChan:
 				return
+
+			case <-consensus.done:
+				return
 			}
 		}
 	}()
@@ -1204,7 +1332,7 @@ func (consensus *Consensus) GenerateVrfAndProof(newBlock *types.Block, vrfBlockN
 
 // ValidateVrfAndProof validates a VRF/Proof from hash of previous block
 func (consensus *Consensus) ValidateVrfAndProof(headerObj types.Header) bool {
-	vrfPk := vrf_bls.NewVRFVerifier(consensus.LeaderPubKey)
+	vrfPk := vrf_bls.NewVRFVerifier(consensus.GetLeaderPubKey())
 
 	var blockHash [32]byte
 	previousHeader := consensus.ChainReader.GetHeaderByNumber(headerObj.Number.Uint64() - 1)
@@ -0,0 +1,167 @@
+package consensus
+
+import (
+	"encoding/binary"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	protobuf "github.com/golang/protobuf/proto"
+	ffi_bls "github.com/harmony-one/bls/ffi/go/bls"
+
+	"github.com/harmony-one/harmony/api/proto"
+	msg_pb "github.com/harmony-one/harmony/api/proto/message"
+	"github.com/harmony-one/harmony/core/types"
+	"github.com/harmony-one/harmony/internal/utils"
+	"github.com/harmony-one/harmony/p2p/p2pimpl"
+)
+
+func TestOnCommitDropsLateCommit(test *testing.T) {
+	leader, peers, priKeys := NewTestCommittee(3)
+	p2pPriKey, _, _ := utils.GenKeyP2P("127.0.0.1", "9902")
+	host, err := p2pimpl.NewHost(&leader, p2pPriKey)
+	if err != nil {
+		test.Fatalf("newhost failure: %v", err)
+	}
+	leaderConsensus, err := New(host, 0, leader, priKeys[0])
+	if err != nil {
+		test.Fatalf("Cannot craeate consensus: %v", err)
+	}
+	validator, err := New(host, 0, leader, priKeys[1])
+	if err != nil {
+		test.Fatalf("Cannot craeate consensus: %v", err)
+	}
+	pubKeys := []*ffi_bls.PublicKey{}
+	for _, peer := range peers {
+		pubKeys = append(pubKeys, peer.ConsensusPubKey)
+	}
+	leaderConsensus.UpdatePublicKeys(pubKeys)
+	validator.UpdatePublicKeys(pubKeys)
+
+	blockHash := common.Hash{0x01}
+	leaderConsensus.blockNum = 5
+	leaderConsensus.PbftLog.AddMessage(&PbftMessage{MessageType: msg_pb.MessageType_ANNOUNCE, BlockNum: 5, BlockHash: blockHash})
+	leaderConsensus.PbftLog.AddMessage(&PbftMessage{MessageType: msg_pb.MessageType_PREPARED, BlockNum: 5, BlockHash: blockHash})
+
+	commitFor := func(blockNum uint64) *msg_pb.Message {
+		validator.blockNum = blockNum
+		validator.blockHash = blockHash
+		blockNumBytes := make([]byte, 8)
+		binary.LittleEndian.PutUint64(blockNumBytes, blockNum)
+		payload, err := proto.GetConsensusMessagePayload(validator.constructCommitMessage(append(blockNumBytes, blockHash[:]...)))
+		if err != nil {
+			test.Fatalf("Failed to get consensus message: %v", err)
+		}
+		msg := &msg_pb.Message{}
+		if err = protobuf.Unmarshal(payload, msg); err != nil {
+			test.Fatalf("Error when unmarshalling a message: %v", err)
+		}
+		return msg
+	}
+
+	leaderConsensus.onCommit(commitFor(4))
+	if len(leaderConsensus.commitSigs) != 0 {
+		test.Error("Commit for an already finalized block should be dropped")
+	}
+	leaderConsensus.onCommit(commitFor(5))
+	if len(leaderConsensus.commitSigs) != 1 {
+		test.Error("Commit for the current block should be recorded")
+	}
+}
+
+func TestSingleNodeCommittee(test *testing.T) {
+	leader, _, priKeys := NewTestCommittee(1)
+	p2pPriKey, _, _ := utils.GenKeyP2P("127.0.0.1", "9902")
+	host, err := p2pimpl.NewHost(&leader, p2pPriKey)
+	if err != nil {
+		test.Fatalf("newhost failure: %v", err)
+	}
+	consensus, err := New(host, 0, leader, priKeys[0])
+	if err != nil {
+		test.Fatalf("Cannot craeate consensus: %v", err)
+	}
+	defer consensus.Close()
+	consensus.UpdatePublicKeys([]*ffi_bls.PublicKey{leader.ConsensusPubKey})
+	consensus.blockNum = 1
+
+	consensus.announce(types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)}))
+	if consensus.phase != Commit {
+		test.Errorf("Single node should be prepared right after announcing, got phase %s", consensus.phase)
+	}
+	select {
+	case viewID := <-consensus.commitFinishChan:
+		if viewID != consensus.viewID {
+			test.Errorf("Expected commits finished for view %d, got %d", consensus.viewID, viewID)
+		}
+	case <-time.After(time.Second):
+		test.Fatal("Single node should not wait for commits from others")
+	}
+
+	msgBytes, _ := consensus.constructCommittedMessage()
+	payload, err := proto.GetConsensusMessagePayload(msgBytes)
+	if err != nil {
+		test.Fatalf("Failed to get consensus message: %v", err)
+	}
+	msg := &msg_pb.Message{}
+	if err = protobuf.Unmarshal(payload, msg); err != nil {
+		test.Fatalf("Error when unmarshalling a message: %v", err)
+	}
+	pbftMsg, err := ParsePbftMessage(msg)
+	if err != nil {
+		test.Fatalf("Unable to parse committed message: %v", err)
+	}
+	if _, err := consensus.verifyCommittedSig(pbftMsg); err != nil {
+		test.Errorf("Self-signed committed message does not verify: %v", err)
+	}
+}
+
+func TestOnPreparedCountsBlockLimitViolations(test *testing.T) {
+	leader, _, priKeys := NewTestCommittee(2)
+	p2pPriKey, _, _ := utils.GenKeyP2P("127.0.0.1", "9902")
+	host, err := p2pimpl.NewHost(&leader, p2pPriKey)
+	if err != nil {
+		test.Fatalf("newhost failure: %v", err)
+	}
+	leaderConsensus, err := New(host, 0, leader, priKeys[0])
+	if err != nil {
+		test.Fatalf("Cannot craeate consensus: %v", err)
+	}
+	defer leaderConsensus.Close()
+	validator, err := New(host, 0, leader, priKeys[1])
+	if err != nil {
+		test.Fatalf("Cannot craeate consensus: %v", err)
+	}
+	defer validator.Close()
+	// the leader alone is a quorum, so it prepares the block right after announcing it
+	pubKeys := []*ffi_bls.PublicKey{leader.ConsensusPubKey}
+	leaderConsensus.UpdatePublicKeys(pubKeys)
+	validator.UpdatePublicKeys(pubKeys)
+	leaderConsensus.blockNum = 1
+	validator.blockNum = 1
+	leaderConsensus.announce(types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)}))
+
+	preparedMsg := func() *msg_pb.Message {
+		msgBytes, _ := leaderConsensus.constructPreparedMessage()
+		payload, err := proto.GetConsensusMessagePayload(msgBytes)
+		if err != nil {
+			test.Fatalf("Failed to get consensus message: %v", err)
+		}
+		msg := &msg_pb.Message{}
+		if err = protobuf.Unmarshal(payload, msg); err != nil {
+			test.Fatalf("Error when unmarshalling a message: %v", err)
+		}
+		return msg
+	}
+
+	validator.MinBlockBytes = len(leaderConsensus.block) + 1
+	for i := uint64(1); i <= 2; i++ {
+		validator.onPrepared(preparedMsg())
+		if violations := validator.BlockLimitViolations(leader.ConsensusPubKey); violations != i {
+			test.Errorf("Expected %d violations by the leader, got %d", i, violations)
+		}
+	}
+	if len(validator.commitSigs) != 0 || validator.phase == Commit {
+		test.Error("Validator should not commit a block under the minimum size")
+	}
+}
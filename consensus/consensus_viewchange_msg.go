@@ -27,7 +27,7 @@ func (consensus *Consensus) constructViewChangeMessage() []byte {
 	vcMsg.SenderPubkey = consensus.PubKey.Serialize()
 
 	// next leader key already updated
-	vcMsg.LeaderPubkey = consensus.LeaderPubKey.Serialize()
+	vcMsg.LeaderPubkey = consensus.GetLeaderPubKey().Serialize()
 
 	preparedMsgs := consensus.PbftLog.GetMessagesByTypeSeqHash(msg_pb.MessageType_PREPARED, consensus.blockNum, consensus.blockHash)
 	preparedMsg := consensus.PbftLog.FindMessageByMaxViewID(preparedMsgs)
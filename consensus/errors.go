@@ -0,0 +1,17 @@
+package consensus
+
+import "errors"
+
+var (
+	// ErrQuorumNotReached is returned when an aggregated signature is signed by
+	// fewer validators than the quorum of the committee.
+	ErrQuorumNotReached = errors.New("not enough signatures for quorum")
+
+	// ErrInvalidSignature is returned when a message or aggregated signature
+	// does not verify against the signer's public keys.
+	ErrInvalidSignature = errors.New("invalid signature")
+
+	// ErrStaleView is returned when a message is for a view older than the
+	// current one.
+	ErrStaleView = errors.New("view ID belongs to the past")
+)
@@ -223,6 +223,12 @@ func ParsePbftMessage(msg *msg_pb.Message) (*PbftMessage, error) {
 	pbftMsg := PbftMessage{}
 	pbftMsg.MessageType = msg.GetType()
 	consensusMsg := msg.GetConsensus()
+	if consensusMsg == nil {
+		return nil, fmt.Errorf("ParsePbftMessage: missing consensus request in %s message", pbftMsg.MessageType)
+	}
+	if len(consensusMsg.BlockHash) != len(common.Hash{}) {
+		return nil, fmt.Errorf("ParsePbftMessage: invalid block hash length %d", len(consensusMsg.BlockHash))
+	}
 
 	pbftMsg.ViewID = consensusMsg.ViewId
 	pbftMsg.BlockNum = consensusMsg.BlockNum
@@ -250,6 +256,9 @@ func ParseViewChangeMessage(msg *msg_pb.Message) (*PbftMessage, error) {
 	}
 
 	vcMsg := msg.GetViewchange()
+	if vcMsg == nil {
+		return nil, fmt.Errorf("ParseViewChangeMessage: missing viewchange request")
+	}
 	pbftMsg.ViewID = vcMsg.ViewId
 	pbftMsg.BlockNum = vcMsg.BlockNum
 	pbftMsg.Payload = make([]byte, len(vcMsg.Payload))
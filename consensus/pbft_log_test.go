@@ -51,6 +51,24 @@ func TestParsePbftMessage(t *testing.T) {
 	}
 }
 
+func TestParsePbftMessageMalformed(t *testing.T) {
+	if _, err := ParsePbftMessage(&msg_pb.Message{Type: msg_pb.MessageType_ANNOUNCE}); err == nil {
+		t.Error("message without consensus request should not parse")
+	}
+	msg := &msg_pb.Message{
+		Type: msg_pb.MessageType_ANNOUNCE,
+		Request: &msg_pb.Message_Consensus{
+			Consensus: &msg_pb.ConsensusRequest{BlockHash: []byte{1, 2, 3}},
+		},
+	}
+	if _, err := ParsePbftMessage(msg); err == nil {
+		t.Error("message with short block hash should not parse")
+	}
+	if _, err := ParseViewChangeMessage(&msg_pb.Message{Type: msg_pb.MessageType_VIEWCHANGE}); err == nil {
+		t.Error("message without viewchange request should not parse")
+	}
+}
+
 func TestGetMessagesByTypeSeqViewHash(t *testing.T) {
 	pbftMsg := PbftMessage{MessageType: msg_pb.MessageType_ANNOUNCE, BlockNum: 2, ViewID: 3, BlockHash: [32]byte{01, 02}}
 	log := NewPbftLog()
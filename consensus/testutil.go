@@ -0,0 +1,26 @@
+package consensus
+
+import (
+	"strconv"
+
+	"github.com/harmony-one/bls/ffi/go/bls"
+
+	"github.com/harmony-one/harmony/crypto/pki"
+	"github.com/harmony-one/harmony/p2p"
+)
+
+// NewTestCommittee returns n local peers with deterministic BLS keys, the
+// first of which is the leader, for tests that need a whole committee.
+// The keys are derived from small integers and must never be used outside tests.
+func NewTestCommittee(n int) (leader p2p.Peer, peers []p2p.Peer, priKeys []*bls.SecretKey) {
+	for i := 0; i < n; i++ {
+		priKey := pki.GetBLSPrivateKeyFromInt(i + 1)
+		priKeys = append(priKeys, priKey)
+		peers = append(peers, p2p.Peer{
+			IP:              "127.0.0.1",
+			Port:            strconv.Itoa(19000 + i),
+			ConsensusPubKey: priKey.GetPublicKey(),
+		})
+	}
+	return peers[0], peers, priKeys
+}
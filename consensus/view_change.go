@@ -3,7 +3,9 @@ package consensus
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -74,6 +76,16 @@ func (phase PbftPhase) String() string {
 	return "Unknown"
 }
 
+// MarshalJSON encodes the mode as its string name
+func (mode Mode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(mode.String())
+}
+
+// MarshalJSON encodes the phase as its string name
+func (phase PbftPhase) MarshalJSON() ([]byte, error) {
+	return json.Marshal(phase.String())
+}
+
 // SetMode set the node mode as required
 func (pm *PbftMode) SetMode(m Mode) {
 	pm.mux.Lock()
@@ -101,7 +113,11 @@ func (pm *PbftMode) GetViewID() uint64 {
 // switchPhase will switch PbftPhase to nextPhase if the desirePhase equals the nextPhase
 func (consensus *Consensus) switchPhase(desirePhase PbftPhase, override bool) {
 	if override {
-		consensus.phase = desirePhase
+		// ResetState re-sets Announce on every round, only report actual switches
+		if consensus.phase != desirePhase {
+			consensus.phase = desirePhase
+			consensus.emitEvent()
+		}
 		return
 	}
 
@@ -116,19 +132,54 @@ func (consensus *Consensus) switchPhase(desirePhase PbftPhase, override bool) {
 	}
 	if nextPhase == desirePhase {
 		consensus.phase = nextPhase
+		consensus.emitEvent()
+	}
+}
+
+// LeaderSelector determines the leader taking over during view change.
+type LeaderSelector interface {
+	// NextLeader returns the leader proposed for viewID, given the ordered
+	// committee public keys and the leader of the view being changed.
+	// It must be deterministic so all validators propose the same leader.
+	NextLeader(publicKeys []*bls.PublicKey, currentLeader *bls.PublicKey, viewID uint64) *bls.PublicKey
+}
+
+// RoundRobinLeaderSelector picks the committee member following the current
+// leader.  If the current leader is not in the committee, the first member is
+// picked.
+type RoundRobinLeaderSelector struct{}
+
+// NextLeader returns the committee member following currentLeader.
+func (RoundRobinLeaderSelector) NextLeader(publicKeys []*bls.PublicKey, currentLeader *bls.PublicKey, viewID uint64) *bls.PublicKey {
+	idx := -1
+	for k, v := range publicKeys {
+		if v.IsEqual(currentLeader) {
+			idx = k
+			break
+		}
 	}
+	idx = (idx + 1) % len(publicKeys)
+	return publicKeys[idx]
+}
+
+// SetLeaderSelector sets the leader selector used during view change.
+func (consensus *Consensus) SetLeaderSelector(selector LeaderSelector) {
+	consensus.leaderSelector = selector
 }
 
 // GetNextLeaderKey uniquely determine who is the leader for given viewID
-func (consensus *Consensus) GetNextLeaderKey() *bls.PublicKey {
-	idx := consensus.getIndexOfPubKey(consensus.LeaderPubKey)
-	if idx == -1 {
+func (consensus *Consensus) GetNextLeaderKey(viewID uint64) *bls.PublicKey {
+	leaderPubKey := consensus.GetLeaderPubKey()
+	if consensus.getIndexOfPubKey(leaderPubKey) == -1 {
 		consensus.getLogger().Warn().
-			Str("key", consensus.LeaderPubKey.SerializeToHexStr()).
+			Str("key", leaderPubKey.SerializeToHexStr()).
 			Msg("GetNextLeaderKey: currentLeaderKey not found")
 	}
-	idx = (idx + 1) % len(consensus.PublicKeys)
-	return consensus.PublicKeys[idx]
+	selector := consensus.leaderSelector
+	if selector == nil {
+		selector = RoundRobinLeaderSelector{}
+	}
+	return selector.NextLeader(consensus.PublicKeys, leaderPubKey, viewID)
 }
 
 func (consensus *Consensus) getIndexOfPubKey(pubKey *bls.PublicKey) int {
@@ -172,18 +223,19 @@ func (consensus *Consensus) startViewChange(viewID uint64) {
 	if consensus.disableViewChange {
 		return
 	}
+	consensus.recordRoundFailure()
 	consensus.consensusTimeout[timeoutConsensus].Stop()
 	consensus.consensusTimeout[timeoutBootstrap].Stop()
 	consensus.mode.SetMode(ViewChanging)
 	consensus.mode.SetViewID(viewID)
-	consensus.LeaderPubKey = consensus.GetNextLeaderKey()
+	consensus.setLeaderPubKey(consensus.GetNextLeaderKey(viewID))
 
 	diff := viewID - consensus.viewID
 	duration := time.Duration(int64(diff) * int64(viewChangeDuration))
 	consensus.getLogger().Info().
 		Uint64("ViewChangingID", viewID).
 		Dur("timeoutDuration", duration).
-		Str("NextLeader", consensus.LeaderPubKey.SerializeToHexStr()).
+		Str("NextLeader", consensus.GetLeaderPubKey().SerializeToHexStr()).
 		Msg("[startViewChange]")
 
 	msgToSend := consensus.constructViewChangeMessage()
@@ -207,7 +259,7 @@ func (consensus *Consensus) onViewChange(msg *msg_pb.Message) {
 		return
 	}
 
-	if len(consensus.viewIDSigs) >= consensus.Quorum() {
+	if consensus.HasQuorum(len(consensus.viewIDSigs)) {
 		consensus.getLogger().Debug().
 			Int("have", len(consensus.viewIDSigs)).
 			Int("need", consensus.Quorum()).
@@ -327,7 +379,7 @@ func (consensus *Consensus) onViewChange(msg *msg_pb.Message) {
 				return
 			}
 			// check has 2f+1 signature in m1 type message
-			if count := utils.CountOneBits(mask.Bitmap); count < consensus.Quorum() {
+			if count := utils.CountOneBits(mask.Bitmap); !consensus.HasQuorum(count) {
 				consensus.getLogger().Debug().
 					Int("need", consensus.Quorum()).
 					Int("have", count).
@@ -391,14 +443,13 @@ func (consensus *Consensus) onViewChange(msg *msg_pb.Message) {
 		Msg("[onViewChange]")
 
 	// received enough view change messages, change state to normal consensus
-	if len(consensus.viewIDSigs) >= consensus.Quorum() {
+	if consensus.HasQuorum(len(consensus.viewIDSigs)) {
 		consensus.mode.SetMode(Normal)
-		consensus.LeaderPubKey = consensus.PubKey
+		atomic.AddUint64(&consensus.roundsViewChanged, 1)
+		consensus.setLeaderPubKey(consensus.PubKey)
 		consensus.ResetState()
 		if len(consensus.m1Payload) == 0 {
-			go func() {
-				consensus.ReadySignal <- struct{}{}
-			}()
+			consensus.signalReady()
 		} else {
 			consensus.getLogger().Debug().
 				Str("From", consensus.phase.String()).
@@ -415,9 +466,7 @@ func (consensus *Consensus) onViewChange(msg *msg_pb.Message) {
 			consensus.prepareBitmap = mask
 
 			// Leader sign and add commit message
-			blockNumBytes := make([]byte, 8)
-			binary.LittleEndian.PutUint64(blockNumBytes, consensus.blockNum)
-			commitPayload := append(blockNumBytes, consensus.blockHash[:]...)
+			commitPayload := ConstructCommitPayload(consensus.blockNum, consensus.blockHash)
 			consensus.commitSigs[consensus.PubKey.SerializeToHexStr()] = consensus.priKey.SignHash(commitPayload)
 			if err = consensus.commitBitmap.SetKey(consensus.PubKey, true); err != nil {
 				consensus.getLogger().Debug().Msg("[OnViewChange] New Leader commit bitmap set failed")
@@ -480,7 +529,7 @@ func (consensus *Consensus) onNewView(msg *msg_pb.Message) {
 	viewIDBytes := make([]byte, 8)
 	binary.LittleEndian.PutUint64(viewIDBytes, recvMsg.ViewID)
 	// check total number of sigs >= 2f+1
-	if count := utils.CountOneBits(m3Mask.Bitmap); count < consensus.Quorum() {
+	if count := utils.CountOneBits(m3Mask.Bitmap); !consensus.HasQuorum(count) {
 		consensus.getLogger().Debug().
 			Int("need", consensus.Quorum()).
 			Int("have", count).
@@ -541,24 +590,23 @@ func (consensus *Consensus) onNewView(msg *msg_pb.Message) {
 	// newView message verified success, override my state
 	consensus.viewID = recvMsg.ViewID
 	consensus.mode.SetViewID(recvMsg.ViewID)
-	consensus.LeaderPubKey = senderKey
+	consensus.setLeaderPubKey(senderKey)
 	consensus.ResetViewChangeState()
 
 	// change view and leaderKey to keep in sync with network
 	if consensus.blockNum != recvMsg.BlockNum {
 		consensus.getLogger().Debug().
-			Str("newLeaderKey", consensus.LeaderPubKey.SerializeToHexStr()).
+			Str("newLeaderKey", consensus.GetLeaderPubKey().SerializeToHexStr()).
 			Uint64("MsgBlockNum", recvMsg.BlockNum).
 			Msg("[onNewView] New Leader Changed")
 		return
 	}
 
 	// NewView message is verified, change state to normal consensus
+	atomic.AddUint64(&consensus.roundsViewChanged, 1)
 	if len(recvMsg.Payload) > 32 {
 		// Construct and send the commit message
-		blockNumHash := make([]byte, 8)
-		binary.LittleEndian.PutUint64(blockNumHash, consensus.blockNum)
-		commitPayload := append(blockNumHash, consensus.blockHash[:]...)
+		commitPayload := ConstructCommitPayload(consensus.blockNum, consensus.blockHash)
 		msgToSend := consensus.constructCommitMessage(commitPayload)
 
 		consensus.getLogger().Info().Msg("onNewView === commit")
@@ -573,7 +621,7 @@ func (consensus *Consensus) onNewView(msg *msg_pb.Message) {
 		consensus.getLogger().Info().Msg("onNewView === announce")
 	}
 	consensus.getLogger().Debug().
-		Str("newLeaderKey", consensus.LeaderPubKey.SerializeToHexStr()).
+		Str("newLeaderKey", consensus.GetLeaderPubKey().SerializeToHexStr()).
 		Msg("new leader changed")
 	consensus.getLogger().Debug().Msg("validator start consensus timer and stop view change timer")
 	consensus.consensusTimeout[timeoutConsensus].Start()
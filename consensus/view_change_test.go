@@ -0,0 +1,81 @@
+package consensus
+
+import (
+	"encoding/json"
+	"testing"
+
+	ffi_bls "github.com/harmony-one/bls/ffi/go/bls"
+
+	"github.com/harmony-one/harmony/crypto/bls"
+)
+
+func TestRoundRobinLeaderSelector(t *testing.T) {
+	pubKeys := []*ffi_bls.PublicKey{}
+	for i := 0; i < 3; i++ {
+		pubKeys = append(pubKeys, bls.RandPrivateKey().GetPublicKey())
+	}
+	selector := RoundRobinLeaderSelector{}
+
+	for i := range pubKeys {
+		next := selector.NextLeader(pubKeys, pubKeys[i], 1)
+		if !next.IsEqual(pubKeys[(i+1)%len(pubKeys)]) {
+			t.Errorf("Wrong next leader after committee member %d", i)
+		}
+	}
+	unknown := bls.RandPrivateKey().GetPublicKey()
+	if next := selector.NextLeader(pubKeys, unknown, 1); !next.IsEqual(pubKeys[0]) {
+		t.Error("Next leader of an unknown leader should be the first committee member")
+	}
+}
+
+type fixedLeaderSelector struct {
+	leader *ffi_bls.PublicKey
+}
+
+func (s fixedLeaderSelector) NextLeader(publicKeys []*ffi_bls.PublicKey, currentLeader *ffi_bls.PublicKey, viewID uint64) *ffi_bls.PublicKey {
+	return s.leader
+}
+
+func TestGetNextLeaderKey(t *testing.T) {
+	consensus := &Consensus{}
+	for i := 0; i < 3; i++ {
+		consensus.PublicKeys = append(consensus.PublicKeys, bls.RandPrivateKey().GetPublicKey())
+	}
+	consensus.setLeaderPubKey(consensus.PublicKeys[0])
+	if next := consensus.GetNextLeaderKey(1); !next.IsEqual(consensus.PublicKeys[1]) {
+		t.Error("Default leader selection should be round robin")
+	}
+
+	consensus.SetLeaderSelector(fixedLeaderSelector{leader: consensus.PublicKeys[2]})
+	if next := consensus.GetNextLeaderKey(1); !next.IsEqual(consensus.PublicKeys[2]) {
+		t.Error("Configured leader selector is not used")
+	}
+}
+
+func TestPhaseAndModeNames(t *testing.T) {
+	names := map[string]bool{}
+	for _, phase := range []PbftPhase{Announce, Prepare, Commit} {
+		name := phase.String()
+		if name == "" || name == "Unknown" || names[name] {
+			t.Errorf("Phase %d has no unique name: %q", phase, name)
+		}
+		names[name] = true
+		encoded, err := json.Marshal(phase)
+		if err != nil || string(encoded) != `"`+name+`"` {
+			t.Errorf("Phase %d is not encoded as its name: %s %v", phase, encoded, err)
+		}
+	}
+
+	names = map[string]bool{}
+	for _, mode := range []Mode{Normal, ViewChanging, Syncing, Listening} {
+		name := mode.String()
+		if name == "" || name == "Unknown" || names[name] {
+			t.Errorf("Mode %d has no unique name: %q", mode, name)
+		}
+		names[name] = true
+		encoded, err := json.Marshal(mode)
+		if err != nil || string(encoded) != `"`+name+`"` {
+			t.Errorf("Mode %d is not encoded as its name: %s %v", mode, encoded, err)
+		}
+	}
+}
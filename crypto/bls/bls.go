@@ -209,6 +209,41 @@ func AggregateMasks(a, b []byte) ([]byte, error) {
 	return m, nil
 }
 
+// PartialAggregate is a collective signature of a sub-committee together with
+// the participation Bitmap of its signers, indexed over the full committee.
+type PartialAggregate struct {
+	Bitmap []byte
+	Sig    *bls.Sign
+}
+
+// MergeAggregates combines the partial aggregates of disjoint sub-committees
+// into one, ORing their Bitmaps and adding their signatures. It fails if two
+// partial aggregates share a signer, who would otherwise be counted twice.
+func MergeAggregates(partials []PartialAggregate) (PartialAggregate, error) {
+	if len(partials) == 0 {
+		return PartialAggregate{}, errors.New("no partial aggregates to merge")
+	}
+	bitmap := make([]byte, len(partials[0].Bitmap))
+	var sig bls.Sign
+	for i, partial := range partials {
+		if len(partial.Bitmap) != len(bitmap) {
+			return PartialAggregate{}, errors.New("mismatching Bitmap lengths")
+		}
+		if partial.Sig == nil {
+			return PartialAggregate{}, ctxerror.New("missing signature in partial aggregate", "index", i)
+		}
+		for j := range bitmap {
+			if bitmap[j]&partial.Bitmap[j] != 0 {
+				return PartialAggregate{}, ctxerror.New("overlapping signers in partial aggregates",
+					"index", i, "byte", j)
+			}
+			bitmap[j] |= partial.Bitmap[j]
+		}
+		sig.Add(partial.Sig)
+	}
+	return PartialAggregate{Bitmap: bitmap, Sig: &sig}, nil
+}
+
 // Policy represents a fully customizable cosigning policy deciding what
 // cosigner sets are and aren't sufficient for a collective signature to be
 // considered acceptable to a verifier. The Check method may inspect the set of
@@ -1,6 +1,8 @@
 package bls
 
 import (
+	"bytes"
+	"fmt"
 	"strings"
 	"testing"
 
@@ -244,3 +246,121 @@ func TestSetMask(test *testing.T) {
 		test.Error("Expected mismatching Bitmap lengths")
 	}
 }
+
+func TestMaskRoundTrip(test *testing.T) {
+	pubKeys := []*bls.PublicKey{}
+	for i := 0; i < 10; i++ {
+		pubKeys = append(pubKeys, RandPrivateKey().GetPublicKey())
+	}
+
+	mask, err := NewMask(pubKeys, nil)
+	if err != nil {
+		test.Fatalf("Failed to create a new Mask: %s", err)
+	}
+	for _, i := range []int{0, 3, 8, 9} {
+		mask.SetBit(i, true)
+	}
+
+	// rebuild the mask from its bitmap with a fresh copy of the same ordered keys
+	freshKeys := []*bls.PublicKey{}
+	for _, key := range pubKeys {
+		freshKey := &bls.PublicKey{}
+		if err := freshKey.Deserialize(key.Serialize()); err != nil {
+			test.Fatalf("Failed to deserialize public key: %s", err)
+		}
+		freshKeys = append(freshKeys, freshKey)
+	}
+	received, err := NewMask(freshKeys, nil)
+	if err != nil {
+		test.Fatalf("Failed to create a new Mask: %s", err)
+	}
+	if err := received.SetMask(mask.Mask()); err != nil {
+		test.Fatalf("Failed to set mask: %s", err)
+	}
+
+	if !bytes.Equal(received.Bitmap, mask.Bitmap) {
+		test.Error("Bitmap does not match after round trip")
+	}
+	for i := range pubKeys {
+		expected, _ := mask.IndexEnabled(i)
+		actual, _ := received.IndexEnabled(i)
+		if expected != actual {
+			test.Errorf("Bit %d does not match after round trip", i)
+		}
+	}
+	if !received.AggregatePublic.IsEqual(mask.AggregatePublic) {
+		test.Error("Aggregate public key does not match after round trip")
+	}
+}
+
+func TestMergeAggregates(test *testing.T) {
+	priKeys := []*bls.SecretKey{}
+	pubKeys := []*bls.PublicKey{}
+	for i := 0; i < 4; i++ {
+		priKey := RandPrivateKey()
+		priKeys = append(priKeys, priKey)
+		pubKeys = append(pubKeys, priKey.GetPublicKey())
+	}
+	partial := func(signers ...int) PartialAggregate {
+		mask, _ := NewMask(pubKeys, nil)
+		sigs := []*bls.Sign{}
+		for _, i := range signers {
+			mask.SetBit(i, true)
+			sigs = append(sigs, priKeys[i].Sign("message"))
+		}
+		return PartialAggregate{Bitmap: mask.Bitmap, Sig: AggregateSig(sigs)}
+	}
+
+	merged, err := MergeAggregates([]PartialAggregate{partial(0, 1), partial(3)})
+	if err != nil {
+		test.Fatalf("Failed to merge disjoint partial aggregates: %s", err)
+	}
+	mask, _ := NewMask(pubKeys, nil)
+	if err := mask.SetMask(merged.Bitmap); err != nil {
+		test.Fatalf("Failed to set merged bitmap: %s", err)
+	}
+	if mask.CountEnabled() != 3 {
+		test.Errorf("Expected 3 signers in the merged bitmap, got %d", mask.CountEnabled())
+	}
+	if !merged.Sig.Verify(mask.AggregatePublic, "message") {
+		test.Error("Merged signature does not verify against the merged signers")
+	}
+
+	if _, err := MergeAggregates([]PartialAggregate{partial(0, 1), partial(1, 2)}); err == nil {
+		test.Error("Partial aggregates sharing a signer should not merge")
+	}
+	if _, err := MergeAggregates(nil); err == nil {
+		test.Error("Merging nothing should fail")
+	}
+}
+
+func BenchmarkAggregateSig(b *testing.B) {
+	for _, size := range []int{10, 100, 500} {
+		sigs := make([]*bls.Sign, size)
+		for i := range sigs {
+			sigs[i] = RandPrivateKey().Sign("test")
+		}
+		b.Run(fmt.Sprintf("committee-%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				AggregateSig(sigs)
+			}
+		})
+	}
+}
+
+func BenchmarkMaskSetKey(b *testing.B) {
+	for _, size := range []int{10, 100, 500} {
+		pubKeys := make([]*bls.PublicKey, size)
+		for i := range pubKeys {
+			pubKeys[i] = RandPrivateKey().GetPublicKey()
+		}
+		b.Run(fmt.Sprintf("committee-%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				mask, _ := NewMask(pubKeys, nil)
+				for _, pubKey := range pubKeys {
+					mask.SetKey(pubKey, true)
+				}
+			}
+		})
+	}
+}
@@ -485,11 +485,15 @@ func (node *Node) InitShardState() (err error) {
 
 	for _, key := range pubKeys {
 		if key.IsEqual(node.Consensus.PubKey) {
+			if _, err := node.Consensus.UpdatePublicKeys(pubKeys); err != nil {
+				return ctxerror.New("[InitShardState] Cannot update public keys",
+					"shardID", shardID,
+					"blockNum", blockNum).WithCause(err)
+			}
 			utils.Logger().Info().
 				Uint64("blockNum", blockNum).
 				Int("numPubKeys", len(pubKeys)).
 				Msg("[InitShardState] Successfully updated public keys")
-			node.Consensus.UpdatePublicKeys(pubKeys)
 			node.Consensus.SetMode(consensus.Normal)
 			return nil
 		}
@@ -1,7 +1,6 @@
 package node
 
 import (
-	"encoding/binary"
 	"sync"
 
 	"github.com/ethereum/go-ethereum/rlp"
@@ -51,9 +50,7 @@ func (node *Node) ExplorerMessageHandler(payload []byte) {
 			return
 		}
 
-		blockNumHash := make([]byte, 8)
-		binary.LittleEndian.PutUint64(blockNumHash, recvMsg.BlockNum)
-		commitPayload := append(blockNumHash, recvMsg.BlockHash[:]...)
+		commitPayload := consensus.ConstructCommitPayload(recvMsg.BlockNum, recvMsg.BlockHash)
 		if !aggSig.VerifyHash(mask.AggregatePublic, commitPayload) {
 			utils.Logger().
 				Error().Err(err).
@@ -570,7 +570,7 @@ func (node *Node) PostConsensusProcessing(newBlock *types.Block) {
 	// Update last consensus time for metrics
 	// TODO: randomly selected a few validators to broadcast messages instead of only leader broadcast
 	node.lastConsensusTime = time.Now().Unix()
-	if node.Consensus.PubKey.IsEqual(node.Consensus.LeaderPubKey) {
+	if node.Consensus.PubKey.IsEqual(node.Consensus.GetLeaderPubKey()) {
 		if node.NodeConfig.ShardID == 0 {
 			node.BroadcastNewBlock(newBlock)
 		} else {
@@ -868,7 +868,9 @@ func (node *Node) transitionIntoNextEpoch(shardState types.ShardState) {
 		}
 		publicKeys = append(publicKeys, key)
 	}
-	node.Consensus.UpdatePublicKeys(publicKeys)
+	if _, err := node.Consensus.UpdatePublicKeys(publicKeys); err != nil {
+		getLogger().Error("Failed to update consensus committee", "error", err)
+	}
 	//	node.DRand.UpdatePublicKeys(publicKeys)
 
 	if node.Blockchain().ShardID() == myShardID {
@@ -958,5 +960,7 @@ func getBinaryPath() (argv0 string, err error) {
 
 // ConsensusMessageHandler passes received message in node_handler to consensus
 func (node *Node) ConsensusMessageHandler(msgPayload []byte) {
-	node.Consensus.MsgChan <- msgPayload
+	if !node.Consensus.PushMessage(msgPayload) {
+		utils.Logger().Debug().Msg("[ConsensusMessageHandler] consensus closed, message dropped")
+	}
 }